@@ -0,0 +1,26 @@
+package main
+
+import "regexp"
+
+// --- NONINTERACTIVE APT ---
+
+// APT_ENV keeps apt-get from stopping to ask anything, which the TUI has no
+// way to answer: debconf takes each package's default, and needrestart
+// restarts services instead of showing its menu.
+const APT_ENV = "export DEBIAN_FRONTEND=noninteractive NEEDRESTART_MODE=a"
+
+// APT_DPKG_OPTS settle a changed config file without a prompt: keep the
+// local one, unless the package's default is to replace it.
+const APT_DPKG_OPTS = "-o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold"
+
+var aptRe = regexp.MustCompile(`(^|[\s;&|(])(apt-get|apt)\s`)
+
+// nonInteractive runs a package manager step without prompts when it calls
+// apt, and leaves any other command as it is.
+func nonInteractive(step installStep) installStep {
+	if !aptRe.MatchString(step.cmd) {
+		return step
+	}
+	step.cmd = APT_ENV + " && " + aptRe.ReplaceAllString(step.cmd, "${1}${2} "+APT_DPKG_OPTS+" ")
+	return step
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- BENCHMARK ---
+
+// benchmarkRun is one timed clean configure and compile.
+type benchmarkRun struct {
+	wall    time.Duration
+	compile time.Duration
+	cpu     time.Duration // user + system, across every compiler process
+	peakKB  int64         // largest resident set of any one process
+	hitRate float64       // ccache hits per cacheable call, -1 without ccache
+}
+
+// benchmarkPhase runs cmd, sending its output to log, and adds its times and
+// memory to r.
+func benchmarkPhase(cmd string, opts options, log *logWriter, r *benchmarkRun) error {
+	c := exec.Command("bash", "-c", asBuildUser(installStep{cmd: cmd}, opts).cmd)
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	c.Stderr = c.Stdout
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		log.println(formatLogLine(line, opts), classifyLine(ansi.Strip(line)))
+	}
+	err = c.Wait()
+	r.wall += time.Since(start)
+	if c.ProcessState != nil {
+		r.cpu += c.ProcessState.UserTime() + c.ProcessState.SystemTime()
+		// Linux reports the largest of the child and its waited-for descendants.
+		if usage, ok := c.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			r.peakKB = max(r.peakKB, usage.Maxrss)
+		}
+	}
+	return err
+}
+
+// ccacheHitRate reads the hits and misses since the last ccache -z, -1 if
+// ccache isn't in use or too old for --print-stats.
+func ccacheHitRate() float64 {
+	if CCACHE_DIR == "" {
+		return -1
+	}
+	cmd := exec.Command("ccache", "--print-stats")
+	cmd.Env = append(os.Environ(), "CCACHE_DIR="+CCACHE_DIR)
+	out, err := cmd.Output()
+	if err != nil {
+		return -1
+	}
+	stats := map[string]float64{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(line, "\t"); ok {
+			stats[key], _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+	}
+	hits := stats["direct_cache_hit"] + stats["preprocessed_cache_hit"]
+	if hits+stats["cache_miss"] == 0 {
+		return -1
+	}
+	return hits / (hits + stats["cache_miss"])
+}
+
+// benchmarkOnce configures and compiles src from scratch in a separate
+// build directory, so the tree an install uses is left alone.
+func benchmarkOnce(src string, opts options, log *logWriter) (benchmarkRun, error) {
+	r := benchmarkRun{hitRate: -1}
+	dir := shellQuote(src + "/build-benchmark")
+	env := ""
+	zeroed := false
+	if CCACHE_DIR != "" {
+		env = fmt.Sprintf("export CCACHE_DIR=%s && ", shellQuote(CCACHE_DIR))
+		// The store the build uses, or the hit rate is since whenever.
+		zero := exec.Command("ccache", "-z")
+		zero.Env = append(os.Environ(), "CCACHE_DIR="+CCACHE_DIR)
+		if out, err := zero.CombinedOutput(); err != nil {
+			log.println(fmt.Sprintf("WARNING: ccache -z failed, no hit rate for this run: %v %s", err, strings.TrimSpace(string(out))), lineWarning)
+		} else {
+			zeroed = true
+		}
+	}
+	configure := fmt.Sprintf("%srm -rf %s && mkdir -p %s && cd %s && cmake %s ..", env, dir, dir, dir, strings.Join(cmakeArgs(opts), " "))
+	if err := benchmarkPhase(configure, opts, log, &r); err != nil {
+		return r, fmt.Errorf("configure: %v", err)
+	}
+	before := r.wall
+	if err := benchmarkPhase(fmt.Sprintf("%scd %s && make -j%d", env, dir, jobCount(opts)), opts, log, &r); err != nil {
+		return r, fmt.Errorf("compile: %v", err)
+	}
+	r.compile = r.wall - before
+	if zeroed {
+		r.hitRate = ccacheHitRate()
+	}
+	return r, nil
+}
+
+func (r benchmarkRun) String() string {
+	hits := "no ccache"
+	if r.hitRate >= 0 {
+		hits = fmt.Sprintf("ccache %.0f%% hits", r.hitRate*100)
+	}
+	return fmt.Sprintf("wall %s (compile %s), CPU %s, peak %.0f MiB, %s",
+		r.wall.Round(100*time.Millisecond), r.compile.Round(100*time.Millisecond), r.cpu.Round(100*time.Millisecond), float64(r.peakKB)/1024, hits)
+}
+
+// medianRun takes the median of each measure separately.
+func medianRun(runs []benchmarkRun) benchmarkRun {
+	pick := func(f func(benchmarkRun) float64) float64 {
+		var xs []float64
+		for _, r := range runs {
+			xs = append(xs, f(r))
+		}
+		return median(xs)
+	}
+	return benchmarkRun{
+		wall:    time.Duration(pick(func(r benchmarkRun) float64 { return float64(r.wall) })),
+		compile: time.Duration(pick(func(r benchmarkRun) float64 { return float64(r.compile) })),
+		cpu:     time.Duration(pick(func(r benchmarkRun) float64 { return float64(r.cpu) })),
+		peakKB:  int64(pick(func(r benchmarkRun) float64 { return float64(r.peakKB) })),
+		hitRate: pick(func(r benchmarkRun) float64 { return r.hitRate }),
+	}
+}
+
+// runBenchmark times opts.runs clean builds of the existing checkout, skipping
+// install, prints each and their median, and records the median in the
+// history. It returns the exit code.
+func runBenchmark(opts options) int {
+	src := sourceDir(opts)
+	if !fileExists(filepath.Join(src, "CMakeLists.txt")) {
+		fmt.Printf("Error: no TIC-80 checkout at %s; run an install with --keep-build or --cache first, or pass --source-dir.\n", src)
+		return 1
+	}
+	log := createLog(opts.logFormat)
+	defer log.Close()
+	for _, line := range logHeader(actionInstall, opts) {
+		log.println(formatLogLine(line, opts), lineNormal)
+	}
+	fmt.Printf("Benchmarking %s: %d run(s), -j%d\n", src, opts.runs, jobCount(opts))
+	start := time.Now()
+	var runs []benchmarkRun
+	var err error
+	for i := 1; i <= opts.runs; i++ {
+		log.println(fmt.Sprintf(">>> Benchmark run %d of %d", i, opts.runs), lineNormal)
+		var r benchmarkRun
+		if r, err = benchmarkOnce(src, opts, log); err != nil {
+			break
+		}
+		runs = append(runs, r)
+		fmt.Printf("Run %d: %s\n", i, r)
+	}
+	exec.Command("bash", "-c", "rm -rf "+shellQuote(src+"/build-benchmark")).Run()
+
+	entry := historyEntry{Start: start, Op: "benchmark", Success: err == nil, Ref: opts.ref, Label: opts.label, Jobs: jobCount(opts)}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Duration = time.Since(start).Round(time.Second).Seconds()
+		appendHistory(entry, start)
+		fmt.Printf("FAILED: %v (see %s)\n", err, LOG_FILE)
+		return 1
+	}
+	result := medianRun(runs)
+	if len(runs) > 1 {
+		fmt.Printf("Median: %s\n", result)
+	}
+	entry.Duration = result.wall.Round(time.Second).Seconds()
+	entry.Compile = result.compile.Round(time.Second).Seconds()
+	entry.CPU = result.cpu.Round(time.Second).Seconds()
+	entry.PeakKB = result.peakKB
+	if result.hitRate >= 0 {
+		entry.CacheHits = &result.hitRate
+	}
+	appendHistory(entry, start)
+	return 0
+}
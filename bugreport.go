@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- BUG REPORT ---
+
+var BUG_REPORT_FILE = filepath.Join(STATE_DIR, "bug-report.tar.gz")
+
+// Commands whose output goes into system.txt.
+var bugReportCommands = []string{
+	"uname -a",
+	"cat /etc/os-release",
+	"cmake --version",
+	"gcc --version",
+	"git --version",
+	"nproc",
+	"df -h " + filepath.Dir(BUILD_DIR),
+}
+
+var secretRe = regexp.MustCompile(`(?i)((?:password|passwd|token|secret|authorization)\s*[=:]\s*)\S+|gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,}`)
+
+// userinfoRe is the user:password@ of a URL, as in a --mirror or a proxy.
+var userinfoRe = regexp.MustCompile(`://[^/\s:@]+:[^@\s]+@`)
+
+// redact masks anything that looks like a credential, plus the value of
+// GITHUB_TOKEN wherever it turns up verbatim.
+func redact(s string) string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		s = strings.ReplaceAll(s, token, "[REDACTED]")
+	}
+	s = userinfoRe.ReplaceAllString(s, "://***@")
+	return secretRe.ReplaceAllStringFunc(s, func(match string) string {
+		if sub := secretRe.FindStringSubmatch(match); sub[1] != "" {
+			return sub[1] + "[REDACTED]"
+		}
+		return "[REDACTED]"
+	})
+}
+
+func systemInfo() string {
+	var s strings.Builder
+	for _, c := range bugReportCommands {
+		out, _ := exec.Command("bash", "-c", c).CombinedOutput()
+		fmt.Fprintf(&s, "$ %s\n%s\n", c, out)
+	}
+	return s.String()
+}
+
+// resolvedConfig lists every flag with the value it ended up with, after
+// --config and the command line were applied.
+func resolvedConfig(opts options) string {
+	var s strings.Builder
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&s, "%s = %s\n", f.Name, f.Value)
+	})
+	s.WriteString("\n")
+	for _, row := range summaryRows(opts) {
+		fmt.Fprintf(&s, "%s: %s\n", row.label, row.value)
+	}
+	return s.String()
+}
+
+type bugReportMsg struct {
+	path string
+	err  error
+}
+
+// createBugReport is Create Bug Report in the menu.
+func createBugReport(opts options) tea.Cmd {
+	return func() tea.Msg {
+		path, err := writeBugReport(opts)
+		return bugReportMsg{path: path, err: err}
+	}
+}
+
+// writeBugReport bundles the last log, system info, the resolved config and
+// CMake's own logs and cache variables into BUG_REPORT_FILE. Missing files are skipped.
+func writeBugReport(opts options) (string, error) {
+	files := []struct{ name, content string }{
+		{"system.txt", systemInfo()},
+		{"config.txt", resolvedConfig(opts)},
+	}
+	for name, path := range map[string]string{
+		"tic80-manager.log": LOG_FILE,
+		"last-report.json":  REPORT_FILE,
+		"crash.log":         CRASH_LOG,
+		"cmake-cache.txt":   CMAKE_CACHE_FILE,
+		"CMakeError.log":    sourceDir(opts) + "/build/CMakeFiles/CMakeError.log",
+		"CMakeOutput.log":   sourceDir(opts) + "/build/CMakeFiles/CMakeOutput.log",
+	} {
+		if data, err := os.ReadFile(path); err == nil {
+			files = append(files, struct{ name, content string }{name, string(data)})
+		}
+	}
+
+	if err := os.MkdirAll(STATE_DIR, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(BUG_REPORT_FILE)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, file := range files {
+		content := redact(file.content)
+		hdr := &tar.Header{
+			Name:    "tic80-bug-report/" + file.name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return BUG_REPORT_FILE, f.Close()
+}
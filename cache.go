@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- STEP CACHE ---
+
+// Stamps live inside the build tree, so wiping the tree also invalidates
+// them and a stamp never outlives the output it vouches for.
+var CACHE_DIR = BUILD_DIR + "/.tic80-cache"
+
+// cached skips step when the hash of inputs (shell commands whose combined
+// output describes everything the step depends on) matches the one stored
+// after its last successful run and output, a file the step produces, still
+// exists. Only use it for steps that are safe to skip outright.
+func cached(step installStep, name, output string, inputs []string) installStep {
+	stamp := CACHE_DIR + "/" + name
+	cmd := fmt.Sprintf(`key=$( { %s; } | sha256sum | cut -d' ' -f1 ) && `+
+		`if [ -f %s ] && [ "$(cat %s 2>/dev/null)" = "$key" ]; then echo "Inputs unchanged since last run, skipping."; `+
+		`else ( %s ) && mkdir -p %s && echo "$key" > %s; fi`,
+		strings.Join(inputs, "; "), shellQuote(output), shellQuote(stamp), step.cmd, shellQuote(CACHE_DIR), shellQuote(stamp))
+	step.cmd = cmd
+	return step
+}
+
+// configureInputs are what the CMake configure step depends on: the checked
+// out commit of TIC-80 and its submodules, and the flags passed to cmake.
+func configureInputs(opts options) []string {
+	return []string{
+		gitIn(sourceDir(opts)) + " rev-parse HEAD",
+		gitIn(sourceDir(opts)) + " submodule status --recursive",
+		"echo " + shellQuote(strings.Join(cmakeArgs(opts), " ")),
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// --- CANCELLING STEPS ---
+
+// Grace period between SIGTERM and SIGKILL when tearing a step down.
+const KILL_GRACE = 3 * time.Second
+
+// stepStartedMsg carries the PID of a step's shell, which is also the ID of
+// its process group.
+type stepStartedMsg struct {
+	step int
+	pid  int
+}
+
+// killStep terminates a step and everything it started (dnf, make, bwrap...)
+// by signalling its whole process group.
+func killStep(pid int) {
+	if pid <= 0 {
+		return
+	}
+	syscall.Kill(-pid, syscall.SIGTERM)
+	time.AfterFunc(KILL_GRACE, func() { syscall.Kill(-pid, syscall.SIGKILL) })
+}
+
+// Steps the rest of the run depends on; skipping one would only make a later
+// step fail less clearly.
+var mandatorySteps = map[string]bool{
+	"Creating build directory...":        true,
+	"Cloning Repository...":              true,
+	"Fetching Submodules...":             true,
+	"Updating Repository...":             true,
+	"Verifying Signature...":             true,
+	"Pinning SOURCE_DATE_EPOCH...":       true,
+	"Building vendored dependencies...":  true,
+	"Configuring CMake (Forcing Pro)...": true,
+	"Compiling...":                       true,
+	"Installing...":                      true,
+	"Checking existing build...":         true,
+	"Checking build tree...":             true,
+	"Recompiling verbosely...":           true,
+}
+
+func skippable(step installStep) bool {
+	return !mandatorySteps[step.desc]
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- CHECK CONFIG ---
+
+// configCheck is one line of --check-config's report.
+type configCheck struct {
+	level string // ok, WARN or FAIL
+	text  string
+}
+
+// configChecks looks at everything a run of a would depend on without
+// running any of it or going to the network.
+func configChecks(a action, opts options) []configCheck {
+	var checks []configCheck
+	add := func(level, text string) { checks = append(checks, configCheck{level, text}) }
+	check := func(err error, ok string) {
+		if err != nil {
+			add("FAIL", err.Error())
+		} else if ok != "" {
+			add("ok", ok)
+		}
+	}
+
+	for _, w := range opts.configWarnings {
+		// A run only warns, but a typo in a key is what linting is for.
+		if strings.Contains(w, "unknown option") {
+			add("FAIL", w)
+		} else {
+			add("WARN", w)
+		}
+	}
+	check(checkHooks(opts), "")
+	if pm := packageManager(opts); pm != "none" && (a == actionDeps || buildsBinary(a) && a != actionInstallExisting) {
+		if _, err := exec.LookPath(pm); err != nil {
+			add("FAIL", fmt.Sprintf("package manager %s not found, try --preset %s or set --deps-tools and --deps-pkgs", pm, detectedPreset()))
+		} else {
+			add("ok", "package manager "+pm)
+		}
+	}
+	if !buildsBinary(a) {
+		return checks
+	}
+
+	if !opts.noInstall {
+		check(checkWritable(opts.prefix), "prefix "+opts.prefix+" is writable")
+	}
+	if opts.output != "" {
+		if err := writableDir(filepath.Dir(opts.output)); err != nil {
+			add("FAIL", fmt.Sprintf("--output %s: %v", opts.output, err))
+		}
+	}
+	if opts.sourceDir != "" {
+		warning, err := checkSourceDir(opts.sourceDir)
+		check(err, "source tree "+opts.sourceDir)
+		if warning != "" {
+			add("WARN", warning)
+		}
+	}
+	if a != actionInstallExisting {
+		check(checkSignatureSetup(opts), "")
+		// The deps step installs these, so missing is only worth a note.
+		for _, tool := range []string{"git", "cmake", "make", "gcc", "g++"} {
+			if _, err := exec.LookPath(tool); err != nil {
+				add("WARN", tool+" not installed yet, the deps step installs it")
+			}
+		}
+		if warning := jobsWarning(opts); warning != "" {
+			add("WARN", warning)
+		}
+	}
+	if opts.sandbox != "" && !sandboxAvailable(opts) {
+		add("WARN", "bwrap not found, the build would run without a sandbox")
+	}
+	return checks
+}
+
+// runCheckConfig prints the resolved configuration and the checks, like
+// nginx -t, and returns the exit code: 1 if any check failed.
+func runCheckConfig(a action, opts options, configPath string) int {
+	if configPath == "" {
+		configPath = "none, defaults and flags only"
+	}
+	fmt.Println("Config:    " + configPath)
+	fmt.Println("Operation: " + operationNames[a])
+	fmt.Println("Distro:    " + osRelease() + " (" + distroKey() + ")")
+	fmt.Println()
+	fmt.Println("Effective configuration:")
+	rows := append(summaryRows(opts), summaryRow{"Log file", LOG_FILE}, summaryRow{"State dir", STATE_DIR})
+	for _, row := range rows {
+		fmt.Printf("  %-16s %s\n", row.label, row.value)
+	}
+	fmt.Println()
+	fmt.Println("Checks:")
+	failed := 0
+	for _, c := range configChecks(a, opts) {
+		fmt.Printf("  %-4s  %s\n", c.level, c.text)
+		if c.level == "FAIL" {
+			failed++
+		}
+	}
+	fmt.Println()
+	if failed > 0 {
+		fmt.Printf("%d problem(s) found.\n", failed)
+		return 1
+	}
+	fmt.Println("Configuration OK.")
+	return 0
+}
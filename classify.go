@@ -0,0 +1,31 @@
+package main
+
+import "regexp"
+
+// --- LINE CLASSES ---
+
+// Lines compilers, cmake, make, git and the package managers print when
+// something went wrong, or might have.
+var (
+	errorLineRe   = regexp.MustCompile(`\b(error|fatal error|fatal):\s|^CMake Error|^E: |^Error: |make(\[\d+\])?: \*\*\* `)
+	warningLineRe = regexp.MustCompile(`\bwarning:\s|^CMake Warning|^W: |^WARNING: `)
+)
+
+type lineClass int
+
+const (
+	lineNormal lineClass = iota
+	lineWarning
+	lineError
+)
+
+// classifyLine looks at a line of step output with its escape codes stripped.
+func classifyLine(line string) lineClass {
+	switch {
+	case errorLineRe.MatchString(line):
+		return lineError
+	case warningLineRe.MatchString(line):
+		return lineWarning
+	}
+	return lineNormal
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestStepLinesClassified checks lines the anchored patterns match are
+// still marked in the log when they are shown with their step and stream
+// tags in front.
+func TestStepLinesClassified(t *testing.T) {
+	useTempState(t)
+	opts := defaultOptions()
+	opts.streams, opts.logFormat = "separate", "html"
+	m := initialModel(opts)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = next.(model)
+	m.state = stateRunning
+	m.steps = []installStep{{desc: "Installing Dependencies..."}, {desc: "Cloning Repository..."}}
+	m.outputs = newStepOutputs(len(m.steps), m.opts)
+	m.running = map[int]int{0: 0, 1: 0}
+	m.errLine = -1
+	m.logFile = createLog(m.opts.logFormat)
+	lines := []struct {
+		text, want string
+	}{
+		{"E: Unable to locate package libglvnd-dev", `<span class="error">[Installing Dependencies] [err] E: Unable`},
+		{"CMake Warning at CMakeLists.txt:12 (message):", `<span class="warning">[Installing Dependencies] [err] CMake Warning`},
+		{"Error: Unable to find a match: ruby-full", `<span class="error">[Installing Dependencies] [err] Error: Unable`},
+	}
+	for _, l := range lines {
+		next, _ := m.Update(stepLineMsg{step: 0, text: l.text, stderr: true})
+		m = next.(model)
+	}
+	m.logFile.Close()
+	data, err := os.ReadFile(LOG_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lines {
+		if !strings.Contains(string(data), l.want) {
+			t.Errorf("no %q in the log:\n%s", l.want, data)
+		}
+	}
+	if m.errLine < 0 {
+		t.Error("the error line wasn't noticed")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- CMAKE CACHE ---
+
+// CMAKE_CACHE_FILE is `cmake -LAH` of the last configured tree, kept after
+// the build tree is cleaned up.
+var CMAKE_CACHE_FILE = filepath.Join(STATE_DIR, "cmake-cache.txt")
+
+// recordCMakeCacheStep saves the cache variables TIC-80's build resolved,
+// with their help strings, and logs the ones that decide a Pro build. -N
+// only reads CMakeCache.txt, so nothing is configured again.
+func recordCMakeCacheStep(src string) installStep {
+	return installStep{
+		desc: "Recording CMake cache...",
+		cmd: fmt.Sprintf("mkdir -p %s && cmake -LAH -N %s > %s && grep -E '^(BUILD_PRO|CMAKE_C_FLAGS|CMAKE_BUILD_TYPE):' %s",
+			shellQuote(STATE_DIR), shellQuote(src+"/build"), shellQuote(CMAKE_CACHE_FILE), shellQuote(CMAKE_CACHE_FILE)),
+		nonFatal: true,
+	}
+}
+
+// cmakeCacheVars parses CMAKE_CACHE_FILE into NAME: value, if it was
+// written since since.
+func cmakeCacheVars(since time.Time) map[string]string {
+	fi, err := os.Stat(CMAKE_CACHE_FILE)
+	if err != nil || fi.ModTime().Before(since) {
+		return nil
+	}
+	data, err := os.ReadFile(CMAKE_CACHE_FILE)
+	if err != nil {
+		return nil
+	}
+	vars := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		// NAME:TYPE=VALUE, between "// help" lines and the "-- Cache values" header.
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "--") {
+			continue
+		}
+		if _, value, ok := strings.Cut(rest, "="); ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// --- COLOR PROFILE ---
+
+// colorProfiles are the values of --color-profile besides auto, which
+// leaves lipgloss to detect it from COLORTERM and TERM.
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"16":        termenv.ANSI,
+	"none":      termenv.Ascii,
+}
+
+func colorProfileNames() []string {
+	names := []string{"auto"}
+	for name := range colorProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// applyColorProfile overrides the detected profile, e.g. for a terminal that
+// handles truecolor without saying so in COLORTERM.
+func applyColorProfile(name string) {
+	if p, ok := colorProfiles[name]; ok {
+		lipgloss.SetColorProfile(p)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- COMPACT STATUS LINE ---
+
+func (m model) elapsed() time.Duration {
+	end := m.runEnd
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(m.runStart).Round(time.Second)
+}
+
+// compactView renders the whole UI as one line, e.g.
+// "TIC-80 ⣾ Compiling [6/10] 4m12s", for embedding in a dashboard.
+func (m model) compactView() string {
+	total := len(m.steps)
+	switch m.state {
+	case stateRunning:
+		desc := "Preflight"
+		if !m.checking {
+			desc = strings.TrimSuffix(m.runningDesc(), "...")
+		}
+		if m.progress != "" {
+			desc += " · " + m.progress
+		}
+		line := fmt.Sprintf("TIC-80 %s %s [%d/%d] %s", m.spinner.View(), desc, min(m.finishedCount()+1, total), total, m.elapsed())
+		// A wrapped status line would no longer redraw in place. Cut by
+		// display width, so wide characters and escapes are counted right.
+		if m.width > 0 {
+			line = ansi.Truncate(line, m.width, "…")
+		}
+		return line
+	case stateDone:
+		if m.err != nil {
+			return fmt.Sprintf("TIC-80 %s %v [%d/%d] %s\n", styleError.Render("FAILED"), m.err, min(m.currentStep+1, total), total, m.elapsed())
+		}
+		return fmt.Sprintf("TIC-80 %s [%d/%d] %s\n", styleSuccess.Render("DONE"), total, total, m.elapsed())
+	}
+	return ""
+}
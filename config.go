@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- CONFIG FILE ---
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, " ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// CONFIG_VERSION is the schema written as "version" in a config file. Files
+// without one are read as the current version: nothing tells a version 1
+// file from one written by hand today.
+const CONFIG_VERSION = 2
+
+// configMigrations[v] upgrades a version v config to v+1 in place and
+// returns warnings describing what it changed.
+var configMigrations = map[int]func(raw map[string]any) []string{
+	1: migrateConfigV1,
+}
+
+// Version 1 predates --patch-sdl, when the SDL2 checkout always ran.
+func migrateConfigV1(raw map[string]any) []string {
+	if !patchSDLUnset(raw) {
+		return nil
+	}
+	raw["patch-sdl"] = true
+	return []string{`version 1 always patched SDL2, so "patch-sdl" is kept on; set "version": 2 to use the current default`}
+}
+
+// patchSDLUnset is whether raw leaves the SDL2 patch to the default, which
+// version 1 had on.
+func patchSDLUnset(raw map[string]any) bool {
+	_, patch := raw["patch-sdl"]
+	_, version := raw["sdl-version"]
+	return !patch && !version
+}
+
+// migrateConfig brings raw up to CONFIG_VERSION. A newer file is loaded as
+// far as it can be, with whatever this build doesn't know ignored.
+func migrateConfig(raw map[string]any) ([]string, error) {
+	v, ok := raw["version"]
+	if !ok {
+		if patchSDLUnset(raw) {
+			return []string{fmt.Sprintf(`no "version", read as version %d, which doesn't patch SDL2 by default; set "version": 1 if it was written when it did`, CONFIG_VERSION)}, nil
+		}
+		return nil, nil
+	}
+	n, ok := v.(float64)
+	if !ok || n != float64(int(n)) || n < 1 {
+		return nil, fmt.Errorf("version must be a positive integer")
+	}
+	version := int(n)
+	delete(raw, "version")
+
+	var warnings []string
+	if version > CONFIG_VERSION {
+		warnings = append(warnings, fmt.Sprintf("version %d is newer than this tool understands (%d)", version, CONFIG_VERSION))
+	}
+	for ; version < CONFIG_VERSION; version++ {
+		warnings = append(warnings, configMigrations[version](raw)...)
+	}
+	return warnings, nil
+}
+
+// dropUnknown removes keys that aren't flags, so an option from another
+// version doesn't stop the rest of the file from loading.
+func dropUnknown(where string, values map[string]any, fs *flag.FlagSet) []string {
+	var warnings []string
+	for key := range values {
+		if key == "config" || fs.Lookup(key) == nil {
+			warnings = append(warnings, fmt.Sprintf("%sunknown option %q ignored", where, key))
+			delete(values, key)
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// loadConfig applies a JSON object whose keys are flag names, e.g.
+// {"version": 2, "op": "install", "timestamps": true}. Flags given on the
+// command line win over the file. A path of "-" reads the config from stdin.
+// A "presets" key defines extra presets for --preset, each an object of the
+// same form. Older versions are migrated and unknown keys only warn; the
+// warnings come back prefixed with the file name.
+func loadConfig(path string, fs *flag.FlagSet) (map[string]preset, []string, error) {
+	var r io.Reader = os.Stdin
+	name := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		r = f
+		name = path
+	}
+
+	var raw map[string]any
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("config %s: %v", name, err)
+	}
+	warnings, err := migrateConfig(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config %s: %v", name, err)
+	}
+
+	presets := map[string]preset{}
+	if defs, ok := raw["presets"]; ok {
+		byName, ok := defs.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("config %s: presets must be an object", name)
+		}
+		for presetName, def := range byName {
+			values, ok := def.(map[string]any)
+			if !ok {
+				return nil, nil, fmt.Errorf("config %s: preset %q must be an object", name, presetName)
+			}
+			desc, _ := values["description"].(string)
+			delete(values, "description")
+			warnings = append(warnings, dropUnknown(fmt.Sprintf("preset %q: ", presetName), values, fs)...)
+			presets[presetName] = preset{desc, values}
+		}
+		delete(raw, "presets")
+	}
+
+	warnings = append(warnings, dropUnknown("", raw, fs)...)
+
+	if err := applyValues("config "+name, raw, fs, explicitFlags(fs)); err != nil {
+		return nil, nil, err
+	}
+	for i, w := range warnings {
+		warnings[i] = "config " + name + ": " + w
+	}
+	return presets, warnings, nil
+}
+
+// flagAliases pairs flags that set the same option.
+var flagAliases = map[string]string{
+	"cmake-arg":  "cmake-flag",
+	"cmake-flag": "cmake-arg",
+}
+
+// explicitFlags are the flags set so far, on the command line or otherwise,
+// together with their aliases.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		if alias, ok := flagAliases[f.Name]; ok {
+			explicit[alias] = true
+		}
+	})
+	return explicit
+}
+
+// applyValues sets each flag named in values unless it is in skip. source
+// prefixes any error.
+func applyValues(source string, values map[string]any, fs *flag.FlagSet, skip map[string]bool) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "config" || fs.Lookup(key) == nil {
+			return fmt.Errorf("%s: unknown option %q", source, key)
+		}
+		if skip[key] {
+			continue
+		}
+		// Lists are applied one value at a time for repeatable flags.
+		list, ok := values[key].([]any)
+		if !ok {
+			list = []any{values[key]}
+		}
+		for _, v := range list {
+			if v == nil {
+				continue
+			}
+			if err := fs.Set(key, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("%s: %s: %v", source, key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		want     string // raw after migrating, as JSON
+		warnings int
+		err      bool
+	}{
+		{"no version", `{"prefix": "/opt/tic80"}`, `{"prefix": "/opt/tic80"}`, 1, false},
+		{"no version with patch-sdl set", `{"patch-sdl": true}`, `{"patch-sdl": true}`, 0, false},
+		{"v1 by number", `{"version": 1, "prefix": "/opt/tic80"}`, `{"prefix": "/opt/tic80", "patch-sdl": true}`, 1, false},
+		{"v1 with patch-sdl set", `{"version": 1, "patch-sdl": false}`, `{"patch-sdl": false}`, 0, false},
+		{"v1 with sdl-version", `{"version": 1, "sdl-version": "release-2.30.0"}`, `{"sdl-version": "release-2.30.0"}`, 0, false},
+		{"already v2", `{"version": 2, "prefix": "/opt/tic80"}`, `{"prefix": "/opt/tic80"}`, 0, false},
+		{"future version", `{"version": 99, "prefix": "/opt/tic80", "new-option": 1}`, `{"prefix": "/opt/tic80", "new-option": 1}`, 1, false},
+		{"version as a string", `{"version": "2"}`, ``, 0, true},
+		{"fractional version", `{"version": 1.5}`, ``, 0, true},
+		{"version zero", `{"version": 0}`, ``, 0, true},
+	}
+	for _, tt := range tests {
+		var raw map[string]any
+		if err := json.Unmarshal([]byte(tt.in), &raw); err != nil {
+			t.Fatal(err)
+		}
+		warnings, err := migrateConfig(raw)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var want map[string]any
+		json.Unmarshal([]byte(tt.want), &want)
+		if !reflect.DeepEqual(raw, want) {
+			t.Errorf("%s: migrated to %v, want %v", tt.name, raw, want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+// TestLoadConfigFutureVersion checks a file from a newer tool still loads,
+// with a warning for its version and for each option this one doesn't know.
+func TestLoadConfigFutureVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"version": 3, "prefix": "/opt/tic80", "new-option": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := defaultOptions()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	bindFlags(fs, &opts)
+	_, warnings, err := loadConfig(path, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.prefix != "/opt/tic80" {
+		t.Errorf("prefix %q, want /opt/tic80", opts.prefix)
+	}
+	joined := strings.Join(warnings, "\n")
+	for _, want := range []string{"version 3 is newer", `unknown option "new-option"`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("warnings %q, missing %q", joined, want)
+		}
+	}
+	if opts.patchSDL {
+		t.Error("a newer config was migrated as version 1")
+	}
+}
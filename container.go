@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// --- CONTAINERS AND PERSISTENT CACHE ---
+
+// CCACHE_DIR is ccache's store for --cache-dir builds, "" without one.
+var CCACHE_DIR = ""
+
+// inContainer spots Docker, Podman and the runtimes that leave their name in
+// PID 1's cgroup path.
+func inContainer() bool {
+	if fileExists("/.dockerenv") || fileExists("/run/.containerenv") || os.Getenv("container") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, hint := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+		if strings.Contains(string(data), hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// cgroupMemLimit is the memory limit of this process's cgroup, v2 or v1, in
+// bytes; ok is false when there is none.
+func cgroupMemLimit() (uint64, bool) {
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		// "max", or v1's "unlimited" of nearly 2^63.
+		if err != nil || limit >= 1<<62 {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
+
+// distroKey names the subdirectory of --cache-dir for this distro release,
+// since objects built against one distro's libraries don't link on another.
+func distroKey() string {
+	id := osReleaseField("ID")
+	if id == "" {
+		return "unknown"
+	}
+	if version := osReleaseField("VERSION_ID"); version != "" {
+		return id + "-" + version
+	}
+	return id
+}
+
+// useCacheDir moves the build tree, step stamps, vendor cache and ccache's
+// store into dir, typically a volume mounted into an ephemeral container.
+func useCacheDir(dir string) {
+	BUILD_DIR = filepath.Join(dir, distroKey())
+	SRC_DIR = BUILD_DIR + "/TIC-80"
+	EPOCH_FILE = BUILD_DIR + "/SOURCE_DATE_EPOCH"
+	CACHE_DIR = BUILD_DIR + "/.tic80-cache"
+	VENDOR_CACHE_DIR = BUILD_DIR + "/vendor"
+	VENDOR_BUNDLE = VENDOR_CACHE_DIR + "/current"
+	if _, err := exec.LookPath("ccache"); err == nil {
+		CCACHE_DIR = BUILD_DIR + "/ccache"
+	}
+}
+
+// containerWarning suggests --cache-dir to a container build that will lose
+// its tree when the container goes.
+func containerWarning(opts options) string {
+	if opts.cacheDir != "" || opts.sourceDir != "" || !inContainer() {
+		return ""
+	}
+	return "running in a container: mount a volume and pass --cache-dir on it to keep the build tree and ccache between runs"
+}
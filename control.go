@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// --- CONTROL SOCKET ---
+
+// A client sends one JSON object per line on the socket:
+//
+//	{"cmd": "start", "op": "install"}   op defaults to --op
+//	{"cmd": "status"}
+//	{"cmd": "cancel"}
+//
+// and reads events back, also one per line. Events from a run go to every
+// connected client; replies to status and rejected commands only to the one
+// that asked.
+type controlRequest struct {
+	Cmd string `json:"cmd"`
+	Op  string `json:"op,omitempty"`
+}
+
+type controlEvent struct {
+	Event   string `json:"event"` // started, step, line, progress, step_done, finished, status or error
+	Op      string `json:"op,omitempty"`
+	Step    int    `json:"step,omitempty"` // 1-based, as in the headless [i/n] lines
+	Total   int    `json:"total,omitempty"`
+	Desc    string `json:"desc,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Stderr  bool   `json:"stderr,omitempty"`
+	Running bool   `json:"running,omitempty"`
+	Warning bool   `json:"warning,omitempty"` // a non-fatal step failed
+	Success bool   `json:"success,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type controlServer struct {
+	opts options
+
+	mu        sync.Mutex
+	clients   map[net.Conn]*json.Encoder
+	running   bool
+	op        action
+	step      int // index of the running step
+	desc      string
+	total     int
+	pid       int
+	cancelled bool
+	finished  chan struct{} // closed once run has written history and the report
+	closing   bool
+}
+
+// runControlSocket serves path until SIGINT or SIGTERM. The socket is only
+// accessible to root, who is the only user allowed to start a build anyway.
+func runControlSocket(path string, opts options) error {
+	if fileExists(path) {
+		// A socket left behind by a server that was killed.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return fmt.Errorf("%s is already being served", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	s := &controlServer{opts: opts, clients: map[net.Conn]*json.Encoder{}}
+	go func() {
+		<-sigs
+		s.cancel()
+		ln.Close()
+	}()
+
+	fmt.Println("Listening for commands on " + path)
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			s.shutdown()
+			return nil
+		}
+		if err != nil {
+			s.shutdown()
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// shutdown refuses new runs and waits for a cancelled one to finish its
+// history and report, so exiting doesn't cut them short.
+func (s *controlServer) shutdown() {
+	s.mu.Lock()
+	s.closing = true
+	running, finished := s.running, s.finished
+	s.mu.Unlock()
+	if running {
+		<-finished
+	}
+}
+
+func (s *controlServer) serve(conn net.Conn) {
+	enc := json.NewEncoder(conn)
+	s.mu.Lock()
+	s.clients[conn] = enc
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req controlRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			s.reply(conn, enc, controlEvent{Event: "error", Error: "bad request: " + err.Error()})
+			continue
+		}
+		switch req.Cmd {
+		case "start":
+			if err := s.start(req.Op); err != nil {
+				s.reply(conn, enc, controlEvent{Event: "error", Error: err.Error()})
+			}
+		case "status":
+			s.reply(conn, enc, s.status())
+		case "cancel":
+			if !s.cancel() {
+				s.reply(conn, enc, controlEvent{Event: "error", Error: "nothing is running"})
+			}
+		default:
+			s.reply(conn, enc, controlEvent{Event: "error", Error: fmt.Sprintf("unknown cmd %q, use start, status or cancel", req.Cmd)})
+		}
+	}
+}
+
+// reply answers the client on conn alone. Like emit it holds mu, so a reply
+// never lands in the middle of an event, and gives up on a client that
+// isn't reading.
+func (s *controlServer) reply(conn net.Conn, enc *json.Encoder, ev controlEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	enc.Encode(ev)
+}
+
+// emit sends ev to every client; one that can't keep up is dropped rather
+// than stalling the build.
+func (s *controlServer) emit(ev controlEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, enc := range s.clients {
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if enc.Encode(ev) != nil {
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+func (s *controlServer) status() controlEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return controlEvent{Event: "status"}
+	}
+	return controlEvent{Event: "status", Running: true, Op: operationNames[s.op], Step: s.step + 1, Total: s.total, Desc: s.desc}
+}
+
+// cancel stops the current run, reporting whether there was one.
+func (s *controlServer) cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return false
+	}
+	s.cancelled = true
+	killStep(s.pid)
+	return true
+}
+
+func (s *controlServer) start(name string) error {
+	if name == "" {
+		name = s.opts.op
+	}
+	a, ok := parseOperation(name)
+	if !ok {
+		return fmt.Errorf("unknown op %q", name)
+	}
+	if len(getSteps(a, s.opts)) == 0 {
+		return noStepsError(a)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("%s is already running", operationNames[s.op])
+	}
+	if s.closing {
+		return errors.New("the server is shutting down")
+	}
+	s.running, s.op, s.step, s.pid, s.cancelled = true, a, 0, 0, false
+	s.finished = make(chan struct{})
+	go s.run(a, s.finished)
+	return nil
+}
+
+// run is runHeadless with events in place of stdout.
+func (s *controlServer) run(a action, finished chan struct{}) {
+	defer close(finished)
+	steps := getSteps(a, s.opts)
+	s.mu.Lock()
+	s.total = len(steps)
+	s.mu.Unlock()
+	s.emit(controlEvent{Event: "started", Op: operationNames[a], Total: len(steps)})
+
+	ev := runEvents{
+		say: func(text string) { s.emit(controlEvent{Event: "line", Text: text}) },
+		step: func(i int, step installStep) {
+			s.mu.Lock()
+			s.step, s.desc, s.pid = i, step.title(), 0
+			s.mu.Unlock()
+			s.emit(controlEvent{Event: "step", Step: i + 1, Total: len(steps), Desc: step.title()})
+		},
+		started: func(pid int) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.pid = pid
+			if s.cancelled {
+				killStep(pid)
+			}
+		},
+		progress: func(i int, text string) {
+			s.emit(controlEvent{Event: "progress", Step: i + 1, Text: text})
+		},
+		line: func(i int, msg stepLineMsg) {
+			s.emit(controlEvent{Event: "line", Step: i + 1, Text: msg.text, Stderr: msg.stderr})
+		},
+		stepDone: func(i int, step installStep, err error) {
+			done := controlEvent{Event: "step_done", Step: i + 1, Desc: step.title()}
+			if err != nil {
+				done.Error, done.Warning = err.Error(), step.nonFatal
+			}
+			s.emit(done)
+		},
+		stopped: func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.cancelled
+		},
+	}
+	err := runUnattended(a, s.opts, steps, ev)
+
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	if err != nil {
+		s.emit(controlEvent{Event: "finished", Op: operationNames[a], Error: err.Error()})
+		return
+	}
+	s.emit(controlEvent{Event: "finished", Op: operationNames[a], Success: true})
+}
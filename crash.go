@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- CRASH RECOVERY ---
+
+var CRASH_LOG = filepath.Join(STATE_DIR, "crash.log")
+
+// crash is filled in by the first panic a crashGuard catches.
+type crash struct {
+	program *tea.Program
+	value   any
+	path    string // where the stack trace went, "" if it couldn't be written
+}
+
+// record writes the panic and its stack to CRASH_LOG and quits the program,
+// which then restores the terminal as on any exit.
+func (c *crash) record(value any, stack []byte) {
+	c.value = value
+	text := fmt.Sprintf("tic80-manager crashed at %s\n\npanic: %v\n\n%s", time.Now().Format(time.RFC3339), value, stack)
+	if os.MkdirAll(STATE_DIR, 0755) == nil && os.WriteFile(CRASH_LOG, []byte(text), 0644) == nil {
+		c.path = CRASH_LOG
+	}
+	if c.program != nil {
+		go c.program.Quit()
+	}
+}
+
+// crashGuard wraps the model so a panic in Init, Update or View ends up in
+// CRASH_LOG instead of as a raw stack trace over the TUI. The steps the
+// model was running are stopped along with it.
+type crashGuard struct {
+	m     tea.Model
+	crash *crash
+}
+
+func (g crashGuard) stopSteps() {
+	if m, ok := g.m.(model); ok {
+		for _, pid := range m.running {
+			killStep(pid)
+		}
+	}
+}
+
+func (g crashGuard) Init() (cmd tea.Cmd) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.crash.record(r, debug.Stack())
+			cmd = nil
+		}
+	}()
+	return g.m.Init()
+}
+
+func (g crashGuard) Update(msg tea.Msg) (next tea.Model, cmd tea.Cmd) {
+	if g.crash.value != nil {
+		return g, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			g.crash.record(r, debug.Stack())
+			g.stopSteps()
+			next, cmd = g, nil
+		}
+	}()
+	m, cmd := g.m.Update(msg)
+	g.m = m
+	return g, cmd
+}
+
+func (g crashGuard) View() (view string) {
+	if g.crash.value != nil {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			g.crash.record(r, debug.Stack())
+			g.stopSteps()
+			view = ""
+		}
+	}()
+	return g.m.View()
+}
+
+// report is the message shown once the terminal is back to normal.
+func (c *crash) report() string {
+	if c.path == "" {
+		return fmt.Sprintf("tic80-manager crashed: %v\nThe stack trace could not be saved to %s.", c.value, CRASH_LOG)
+	}
+	return fmt.Sprintf("tic80-manager crashed: %v\nThe details are in %s, please attach it (or a Create Bug Report bundle) to a bug report.", c.value, c.path)
+}
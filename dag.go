@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- STEP SCHEDULING ---
+
+// stepDeps resolves steps[i].dependsOn to indices, each name matching the
+// nearest earlier step with that desc.
+func stepDeps(steps []installStep, i int) []int {
+	if steps[i].dependsOn == nil {
+		deps := make([]int, i)
+		for j := range deps {
+			deps[j] = j
+		}
+		return deps
+	}
+	var deps []int
+	for _, name := range steps[i].dependsOn {
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].desc == name {
+				deps = append(deps, j)
+				break
+			}
+		}
+	}
+	return deps
+}
+
+// readySteps are the steps not started yet whose dependencies have finished.
+func (m model) readySteps() []int {
+	var ready []int
+	for i := range m.steps {
+		if m.started[i] {
+			continue
+		}
+		ok := true
+		for _, j := range stepDeps(m.steps, i) {
+			if !m.finished[j] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
+
+// launchReady starts every step that can run now.
+func (m *model) launchReady() []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, i := range m.readySteps() {
+		cmds = append(cmds, m.startStep(i))
+	}
+	return cmds
+}
+
+// waitSteps keeps reading the stream while any step is still running; exactly
+// one read is outstanding at a time.
+func (m model) waitSteps() tea.Cmd {
+	if len(m.running) == 0 {
+		return nil
+	}
+	return waitForStepMsg(m.stream)
+}
+
+// runningSteps lists the running steps in pipeline order.
+func (m model) runningSteps() []int {
+	running := make([]int, 0, len(m.running))
+	for i := range m.running {
+		running = append(running, i)
+	}
+	sort.Ints(running)
+	return running
+}
+
+func (m model) runningDesc() string {
+	var descs []string
+	for _, i := range m.runningSteps() {
+		descs = append(descs, m.steps[i].title())
+	}
+	return strings.Join(descs, " + ")
+}
+
+func (m model) finishedCount() int {
+	n := 0
+	for _, done := range m.finished {
+		if done {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// --- DEMO CARTS ---
+
+// DEMOS_MANIFEST lists the carts --install-demos copied, so uninstall only
+// removes those and leaves the user's own carts alone.
+var DEMOS_MANIFEST = filepath.Join(STATE_DIR, "demos-manifest.txt")
+
+// demosOwner is the user the carts are for: the one who ran sudo, if any.
+func demosOwner() string {
+	return os.Getenv("SUDO_USER")
+}
+
+func demosDir() string {
+	home, _ := os.UserHomeDir()
+	if name := demosOwner(); name != "" {
+		if u, err := user.Lookup(name); err == nil {
+			home = u.HomeDir
+		}
+	}
+	return filepath.Join(home, ".local/share/tic80/carts")
+}
+
+// installDemosStep copies the repo's demos without overwriting a cart of the
+// same name, recording the new files first. The copy runs as the owner so
+// nothing in their home ends up owned by root.
+func installDemosStep(srcDir string) installStep {
+	dir := demosDir()
+	copyCmd := fmt.Sprintf("mkdir -p %s && cp -rn . %s/", shellQuote(dir), shellQuote(dir))
+	if owner := demosOwner(); owner != "" {
+		copyCmd = fmt.Sprintf("runuser -u %s -- sh -c %s", shellQuote(owner), shellQuote(copyCmd))
+	}
+	record := fmt.Sprintf(`find . -type f -printf '%%P\n' | while read -r f; do [ -e %s/"$f" ] || echo %s/"$f"; done >> %s`,
+		shellQuote(dir), shellQuote(dir), shellQuote(DEMOS_MANIFEST))
+	return installStep{
+		desc:     "Installing demo carts...",
+		cmd:      fmt.Sprintf("cd %s && mkdir -p %s && %s && %s", shellQuote(srcDir+"/demos"), shellQuote(STATE_DIR), record, copyCmd),
+		nonFatal: true,
+	}
+}
+
+func removeDemosStep() installStep {
+	return installStep{
+		desc: "Removing demo carts...",
+		cmd: fmt.Sprintf("if [ -f %s ]; then xargs -r -d '\\n' rm -f < %s && rm -f %s; fi; find %s -depth -type d -empty -delete 2>/dev/null || true",
+			shellQuote(DEMOS_MANIFEST), shellQuote(DEMOS_MANIFEST), shellQuote(DEMOS_MANIFEST), shellQuote(demosDir())),
+		nonFatal: true,
+	}
+}
+
+// installedDemos is what removeDemosStep would delete.
+func installedDemos() []string {
+	data, err := os.ReadFile(DEMOS_MANIFEST)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	// One path per line; cart names can have spaces.
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
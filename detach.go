@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// --- DETACHED BUILDS ---
+
+// detach re-runs this binary headless in its own session with the same
+// arguments, minus --detach, and returns its PID. Output goes to LOG_FILE as
+// for any headless run. The child inherits lock, so the instance lock passes
+// straight to it.
+func detach(lock *os.File) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	args := []string{"--headless"}
+	for _, arg := range os.Args[1:] {
+		name := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+		if name == "detach" {
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer devNull.Close()
+	cmd := exec.Command(self, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = devNull, devNull, devNull
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if lock != nil {
+		// The first of ExtraFiles is fd 3.
+		cmd.ExtraFiles = []*os.File{lock}
+		cmd.Env = append(os.Environ(), LOCK_FD_ENV+"=3")
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// attachStatus describes the process an --attach session is watching.
+func attachStatus(pid int) string {
+	if processAlive(pid) {
+		return fmt.Sprintf("PID %d: running", pid)
+	}
+	if entries := loadHistory(); len(entries) > 0 {
+		if entries[0].Success {
+			return fmt.Sprintf("PID %d: finished, %s succeeded", pid, entries[0].Op)
+		}
+		return fmt.Sprintf("PID %d: finished, %s failed: %s", pid, entries[0].Op, entries[0].Error)
+	}
+	return fmt.Sprintf("PID %d: finished", pid)
+}
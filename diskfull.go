@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// --- DISK FULL ---
+
+// LOW_DISK_FREE is how little free space makes a failed step count as a
+// full disk even when its output no longer says so.
+const LOW_DISK_FREE = 64 << 20
+
+var (
+	ENOSPC_PATTERNS = []string{"No space left on device", "ENOSPC"}
+	// An absolute path; diskFullError skips the "/usr/bin/ld:" a tool
+	// prefixes its messages with.
+	diskPathRe = regexp.MustCompile(`(?:^|[\s'"(])(/[^\s:'"()]+)`)
+)
+
+// DiskFullError is a step that failed because a filesystem filled up.
+type DiskFullError struct {
+	*StepError
+	Path string // mount point of the full filesystem, or the path under it
+	Free uint64 // bytes free when the failure was reported
+}
+
+func (e *DiskFullError) Error() string {
+	return fmt.Sprintf("%s: ran out of disk space on %s (%.0f MiB free now). Free some space and retry, or build elsewhere with --cache-dir DIR; without --keep-build or --cache the build tree (%s) is removed after each run",
+		e.StepError.Error(), e.Path, float64(e.Free)/(1<<20), BUILD_DIR)
+}
+
+func (e *DiskFullError) Unwrap() error { return e.StepError }
+
+// diskFullError explains se by a full disk, nil if it doesn't look like one.
+// The path comes from the line that said so, else it is the build tree.
+func diskFullError(se *StepError, tail []string) *DiskFullError {
+	path := ""
+	matched := false
+	for _, line := range tail {
+		if !containsAny(line, ENOSPC_PATTERNS) {
+			continue
+		}
+		matched = true
+		for _, m := range diskPathRe.FindAllStringSubmatchIndex(line, -1) {
+			if m[2] == 0 && strings.HasPrefix(line[m[3]:], ":") {
+				continue
+			}
+			path = line[m[2]:m[3]]
+		}
+	}
+	if path == "" {
+		path = BUILD_DIR
+	}
+	dir := existingParent(path)
+	free, ok := diskFree(dir)
+	if !ok || !matched && (se.ExitCode < 0 || free >= LOW_DISK_FREE) {
+		return nil
+	}
+	return &DiskFullError{StepError: se, Path: mountPoint(dir), Free: free}
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// existingParent is path or its nearest ancestor that exists, since the
+// file that couldn't be written usually doesn't.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil || path == "/" || path == "." {
+			return path
+		}
+		path = filepath.Dir(path)
+	}
+}
+
+// diskFree is how many bytes an unprivileged user can still write on the
+// filesystem holding dir.
+func diskFree(dir string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return st.Bavail * uint64(st.Bsize), true
+}
+
+// mountPoint walks up from dir while the device stays the same.
+func mountPoint(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	dev := func(p string) (uint64, bool) {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return 0, false
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			return uint64(st.Dev), true
+		}
+		return 0, false
+	}
+	here, ok := dev(dir)
+	if !ok {
+		return dir
+	}
+	for dir != "/" {
+		parent := filepath.Dir(dir)
+		if d, ok := dev(parent); !ok || d != here {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
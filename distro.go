@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// --- DISTRO DEFINITIONS ---
+
+// DISTROS_DIR holds user-supplied distro definitions, one JSON file each,
+// for distros the built-in presets don't cover, e.g. void.json:
+//
+//	{"ids": ["void"], "description": "Void Linux, xbps",
+//	 "install": "xbps-install -y {packages}", "tools": ["base-devel"],
+//	 "packages": {"git": "git", "cmake": "cmake", ...}}
+//
+// Each becomes a preset named after its file.
+var DISTROS_DIR = filepath.Join(CONFIG_DIR, "distros")
+
+// DISTRO_PACKAGES are the dependencies a definition has to map to its own
+// package names; "" means the distro needs nothing extra for it. The
+// compiler and make come from "tools".
+var DISTRO_PACKAGES = []string{"git", "cmake", "ruby", "rake", "gl", "glu", "glut", "alsa", "x11", "xext", "xcursor", "xi", "xrandr", "curl"}
+
+type distroDef struct {
+	IDs         []string          `json:"ids"` // matched against os-release ID and ID_LIKE
+	Description string            `json:"description"`
+	Install     string            `json:"install"` // command, {packages} replaced by the names
+	Tools       []string          `json:"tools"`
+	Packages    map[string]string `json:"packages"`
+
+	name string
+}
+
+// distroDefs are the definitions loaded at startup, in file name order.
+var distroDefs []distroDef
+
+func (d distroDef) validate() error {
+	if len(d.IDs) == 0 {
+		return fmt.Errorf("ids must list at least one os-release ID")
+	}
+	if !strings.Contains(d.Install, "{packages}") {
+		return fmt.Errorf("install must contain {packages}")
+	}
+	var missing []string
+	for _, dep := range DISTRO_PACKAGES {
+		if _, ok := d.Packages[dep]; !ok {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("packages is missing %s", strings.Join(missing, ", "))
+	}
+	for dep := range d.Packages {
+		if !slices.Contains(DISTRO_PACKAGES, dep) {
+			return fmt.Errorf("packages: unknown dependency %q, expected %s", dep, strings.Join(DISTRO_PACKAGES, ", "))
+		}
+	}
+	return nil
+}
+
+// installCmd fills the install template, "" when there's nothing to install.
+func (d distroDef) installCmd(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return ""
+	}
+	return strings.ReplaceAll(d.Install, "{packages}", strings.Join(pkgs, " "))
+}
+
+func (d distroDef) preset() preset {
+	var pkgs []string
+	for _, dep := range DISTRO_PACKAGES {
+		pkgs = append(pkgs, strings.Fields(d.Packages[dep])...)
+	}
+	desc := d.Description
+	if desc == "" {
+		desc = "from " + d.name + ".json"
+	}
+	return preset{desc, map[string]any{
+		"deps-tools": d.installCmd(d.Tools),
+		"deps-pkgs":  d.installCmd(pkgs),
+	}}
+}
+
+// loadDistros reads every definition in DISTROS_DIR; a file that doesn't
+// match the schema is an error, like a broken config.
+func loadDistros() ([]distroDef, error) {
+	paths, _ := filepath.Glob(filepath.Join(DISTROS_DIR, "*.json"))
+	sort.Strings(paths)
+	var defs []distroDef
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var d distroDef
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&d); err != nil {
+			return nil, fmt.Errorf("distro %s: %v", path, err)
+		}
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("distro %s: %v", path, err)
+		}
+		d.name = strings.TrimSuffix(filepath.Base(path), ".json")
+		if _, ok := builtinPresets[d.name]; ok {
+			return nil, fmt.Errorf("distro %s: %s is a built-in preset, rename the file", path, d.name)
+		}
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+// detectedDistro is the first loaded definition for the running distro.
+func detectedDistro() (distroDef, bool) {
+	ids := strings.Fields(osReleaseField("ID") + " " + osReleaseField("ID_LIKE"))
+	for _, d := range distroDefs {
+		for _, id := range d.IDs {
+			if slices.Contains(ids, id) {
+				return d, true
+			}
+		}
+	}
+	return distroDef{}, false
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// --- DOCKERFILE EXPORT ---
+
+// DOCKER_IMAGES maps an os-release ID to its official image.
+var DOCKER_IMAGES = map[string]string{
+	"fedora":              "fedora",
+	"rocky":               "rockylinux",
+	"almalinux":           "almalinux",
+	"debian":              "debian",
+	"raspbian":            "debian",
+	"ubuntu":              "ubuntu",
+	"arch":                "archlinux",
+	"opensuse-leap":       "opensuse/leap",
+	"opensuse-tumbleweed": "opensuse/tumbleweed",
+}
+
+// PM_IMAGES is the image for a package manager's own distro, used when the
+// deps commands aren't for the one we run on.
+var PM_IMAGES = map[string]string{
+	"dnf":     "fedora:latest",
+	"apt-get": "debian:stable",
+	"apt":     "debian:stable",
+	"pacman":  "archlinux:latest",
+	"zypper":  "opensuse/tumbleweed:latest",
+}
+
+// baseImage is --base-image, else the image of the running distro if the
+// deps commands' package manager is installed here, else that package
+// manager's distro.
+func baseImage(opts options) (string, error) {
+	if opts.baseImage != "" {
+		return opts.baseImage, nil
+	}
+	pm := packageManager(opts)
+	if repo, ok := DOCKER_IMAGES[osReleaseField("ID")]; ok {
+		if _, err := exec.LookPath(pm); err == nil {
+			tag := osReleaseField("VERSION_ID")
+			if tag == "" {
+				tag = "latest"
+			}
+			return repo + ":" + tag, nil
+		}
+	}
+	if image, ok := PM_IMAGES[pm]; ok {
+		return image, nil
+	}
+	return "", fmt.Errorf("no base image known for package manager %s, pass --base-image", pm)
+}
+
+// dockerOptions are opts as they can work inside a docker build: root with
+// no one to hand the build to, and nothing that needs the host (its hooks,
+// a desktop session, systemd, bwrap, the CPU governor). The second result
+// says what was dropped.
+func dockerOptions(opts options) (options, []string, error) {
+	if opts.sourceDir != "" {
+		return opts, nil, errors.New("--export-dockerfile clones TIC-80 in the image and can't use --source-dir")
+	}
+	var dropped []string
+	drop := func(on *bool, flag string) {
+		if *on {
+			dropped = append(dropped, flag)
+			*on = false
+		}
+	}
+	drop(&opts.performance, "--performance")
+	drop(&opts.installService, "--install-service")
+	drop(&opts.installDemos, "--install-demos")
+	drop(&opts.registerMime, "--register-mime")
+	drop(&opts.cache, "--cache")
+	if opts.sandbox != "" {
+		dropped = append(dropped, "--sandbox")
+		opts.sandbox = ""
+	}
+	if opts.wrapper != "" {
+		// For this machine's load; the image may not even have ionice.
+		dropped = append(dropped, "--wrapper")
+		opts.wrapper = ""
+	}
+	if opts.preInstallHook != "" || opts.postInstallHook != "" {
+		dropped = append(dropped, "the install hooks")
+		opts.preInstallHook, opts.postInstallHook = "", ""
+	}
+	if opts.jobs == "auto" {
+		// Capped by our memory, not the builder's: leave it to $(nproc).
+		opts.jobs = ""
+	}
+	opts.allowRoot = true
+	opts.keepBuild = false
+	return opts, dropped, nil
+}
+
+// renderDockerfile writes the install steps as three stages: deps with the
+// toolchain, build on top of it with TIC-80 installed (the CI image), and a
+// scratch stage holding only the binary, for `docker build -o`.
+func renderDockerfile(opts options) (string, error) {
+	opts, dropped, err := dockerOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	image, err := baseImage(opts)
+	if err != nil {
+		return "", err
+	}
+	var deps []string
+	for _, step := range depsSteps(opts) {
+		deps = append(deps, step.desc)
+	}
+
+	var s strings.Builder
+	s.WriteString("# syntax=docker/dockerfile:1\n")
+	s.WriteString("# Generated by tic80-manager, building as --op install would.\n")
+	if len(dropped) > 0 {
+		s.WriteString("# Left out, since they need the host: " + strings.Join(dropped, ", ") + ".\n")
+	}
+	s.WriteString("# docker build -o out .            puts the tic80 binary in out/\n")
+	s.WriteString("# docker build --target build .    gives the image it was built in\n")
+	fmt.Fprintf(&s, "\nFROM %s AS deps\n", image)
+	s.WriteString("SHELL [\"/bin/bash\", \"-o\", \"pipefail\", \"-c\"]\n")
+	if pm := packageManager(opts); pm == "apt-get" || pm == "apt" {
+		// An image ships without package lists.
+		s.WriteString("RUN apt-get update\n")
+	}
+	stage := "deps"
+	for _, step := range getSteps(actionInstall, opts) {
+		if stage == "deps" && !slices.Contains(deps, step.desc) {
+			stage = "build"
+			s.WriteString("\nFROM deps AS build\n")
+		}
+		s.WriteString("\n# " + step.desc + "\n")
+		cmd := step.cmd
+		if step.nonFatal {
+			cmd = fmt.Sprintf("( %s ) || echo %s >&2", cmd, shellQuote("WARNING: "+step.desc+" failed, continuing"))
+		}
+		if strings.Contains(cmd, "\n") {
+			s.WriteString("RUN <<'STEP'\n" + cmd + "\nSTEP\n")
+		} else {
+			s.WriteString("RUN " + cmd + "\n")
+		}
+	}
+	bin := binPath(opts.prefix)
+	fmt.Fprintf(&s, "\nFROM scratch\nCOPY --from=build %s /tic80\n", bin)
+	return s.String(), nil
+}
+
+// writeDockerfile only writes the file; nothing in it is executed.
+func writeDockerfile(path string, opts options) error {
+	text, err := renderDockerfile(opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- DOWNLOAD SIZE ---
+
+const GITHUB_REPO_URL = "https://api.github.com/repos/nesbox/TIC-80"
+
+// Rough sizes of a full clone: the vendored submodules aren't in the
+// repository's own size, and CLONE_ESTIMATE_MB stands in for both when the
+// API can't be reached.
+const (
+	CLONE_ESTIMATE_MB      = 180
+	SUBMODULES_ESTIMATE_MB = 120
+)
+
+// repoSizeMsg carries TIC-80's repository size from the GitHub API, in KB;
+// 0 when it couldn't be fetched.
+type repoSizeMsg struct {
+	kb int64
+}
+
+// fetchRepoSize asks the API for the repository size, authenticated with
+// GITHUB_TOKEN like fetchRateLimitReset.
+func fetchRepoSize() tea.Cmd {
+	return func() tea.Msg {
+		req, err := http.NewRequest("GET", GITHUB_REPO_URL, nil)
+		if err != nil {
+			return repoSizeMsg{}
+		}
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := githubClient.Do(req)
+		if err != nil {
+			return repoSizeMsg{}
+		}
+		defer resp.Body.Close()
+		var repo struct {
+			Size int64 `json:"size"`
+		}
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&repo) != nil {
+			return repoSizeMsg{}
+		}
+		return repoSizeMsg{repo.Size}
+	}
+}
+
+// clonesSource is whether a clones or fetches TIC-80's source.
+func clonesSource(a action, opts options) bool {
+	return (a == actionInstall || a == actionUpgrade || a == actionCleanReinstall) && opts.sourceDir == ""
+}
+
+// repoSizeCmd fetches the repository size the first time the summary of an
+// action that clones opens, so nothing else waits on or talks to the API.
+func (m *model) repoSizeCmd() tea.Cmd {
+	if m.sizeAsked || !clonesSource(m.pending, m.opts) {
+		return nil
+	}
+	m.sizeAsked = true
+	return fetchRepoSize()
+}
+
+// downloadSummary is roughly what a's clone or fetch will pull, "" when it
+// doesn't touch the network for the source.
+func downloadSummary(a action, opts options, repoKB int64) string {
+	if !clonesSource(a, opts) {
+		return ""
+	}
+	if opts.cache && fileExists(SRC_DIR+"/.git") {
+		return "only what changed since the kept checkout"
+	}
+	if repoKB == 0 {
+		return fmt.Sprintf("~%d MB (estimate, clone with submodules)", CLONE_ESTIMATE_MB)
+	}
+	return fmt.Sprintf("~%d MB (clone with submodules)", repoKB/1024+SUBMODULES_ESTIMATE_MB)
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// TestRepoSizeOnlyForClones checks the API is only asked for an action that
+// clones, and only once.
+func TestRepoSizeOnlyForClones(t *testing.T) {
+	m := initialModel(defaultOptions())
+	for _, a := range []action{actionUninstall, actionInstallExisting, actionDeps} {
+		m.pending = a
+		if m.repoSizeCmd() != nil {
+			t.Errorf("%s fetches the repository size", operationNames[a])
+		}
+	}
+	m.pending = actionInstall
+	if m.repoSizeCmd() == nil {
+		t.Fatal("install doesn't fetch the repository size")
+	}
+	if m.repoSizeCmd() != nil {
+		t.Error("fetched the repository size twice")
+	}
+
+	m = initialModel(defaultOptions())
+	m.opts.sourceDir = "/src/TIC-80"
+	m.pending = actionInstall
+	if m.repoSizeCmd() != nil {
+		t.Error("fetches the repository size with --source-dir")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// --- BUILD ENVIRONMENT ---
+
+// ENV_VARS are the variables that change what the compile produces.
+var ENV_VARS = []string{"CC", "CXX", "CFLAGS", "CXXFLAGS", "LDFLAGS", "CMAKE_GENERATOR", "PKG_CONFIG_PATH", "SOURCE_DATE_EPOCH"}
+
+// toolVersion is the first line of `name --version`, or "not found".
+func toolVersion(name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, "--version").Output()
+	if err != nil {
+		return name + " not found"
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(line)
+}
+
+// osReleaseField is a value from /etc/os-release, e.g. ID or PRETTY_NAME.
+func osReleaseField(key string) string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, key+"="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
+
+func osRelease() string {
+	if name := osReleaseField("PRETTY_NAME"); name != "" {
+		return name
+	}
+	return "unknown OS"
+}
+
+func kernelRelease() string {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// checkoutCommit is the commit of a kept checkout. A fresh clone doesn't
+// exist yet when the header is written; its commit is in the clone output.
+func checkoutCommit(opts options) string {
+	out, err := exec.Command("git", gitInArgs(sourceDir(opts), "rev-parse", "HEAD")...).Output()
+	if err != nil && opts.sourceDir != "" {
+		return "unknown (" + opts.sourceDir + " is not a git checkout)"
+	}
+	if err != nil {
+		return "not cloned yet (" + refSummary(opts) + ")"
+	}
+	if opts.sourceDir != "" {
+		return strings.TrimSpace(string(out)) + " (" + opts.sourceDir + ")"
+	}
+	return strings.TrimSpace(string(out)) + " (existing checkout)"
+}
+
+// environmentLines snapshot the toolchain, so two logs from different
+// machines can be compared line by line.
+func environmentLines(opts options) []string {
+	var vars []string
+	for _, name := range ENV_VARS {
+		if v, ok := os.LookupEnv(name); ok {
+			vars = append(vars, name+"="+v)
+		}
+	}
+	if len(vars) == 0 {
+		vars = []string{"none set"}
+	}
+	return []string{
+		"=== OS: " + osRelease() + ", kernel " + kernelRelease(),
+		"=== Compilers: " + toolVersion("gcc") + "; " + toolVersion("g++"),
+		"=== Tools: " + toolVersion("cmake") + "; " + toolVersion("make") + "; " + toolVersion("git"),
+		"=== Env: " + redact(strings.Join(vars, " ")),
+		"=== Commit: " + checkoutCommit(opts),
+	}
+}
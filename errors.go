@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- STEP ERRORS ---
+
+// STEP_ERROR_TAIL is how many trailing output lines a StepError keeps.
+const STEP_ERROR_TAIL = 20
+
+// StepError is a step that failed. The typed errors below wrap it so callers
+// can tell what kind of failure it was with errors.As.
+type StepError struct {
+	Step     string
+	ExitCode int    // -1 if the command never ran
+	Output   string // last lines of combined stdout/stderr
+	Err      error
+	Start    time.Time // when the step was started
+}
+
+func (e *StepError) Error() string {
+	if e.ExitCode < 0 {
+		return fmt.Sprintf("%s could not start: %v", strings.TrimSuffix(e.Step, "..."), e.Err)
+	}
+	return fmt.Sprintf("%s failed with exit code %d", strings.TrimSuffix(e.Step, "..."), e.ExitCode)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// DependencyError is a failed package manager step.
+type DependencyError struct{ *StepError }
+
+func (e *DependencyError) Unwrap() error { return e.StepError }
+
+// NetworkError is a failed clone, fetch or download.
+type NetworkError struct{ *StepError }
+
+func (e *NetworkError) Unwrap() error { return e.StepError }
+
+// CompileError is a failed configure or build step.
+type CompileError struct {
+	*StepError
+	Vendor string // e.g. vendor/sdl2 when the failure was in a vendored library
+}
+
+func (e *CompileError) Error() string {
+	if e.Vendor == "" {
+		return e.StepError.Error()
+	}
+	return e.StepError.Error() + " in " + e.Vendor
+}
+
+func (e *CompileError) Unwrap() error { return e.StepError }
+
+var (
+	// make's "*** [target] Error 1", which names the target that failed.
+	makeFailRe = regexp.MustCompile(`make(\[\d+\])?: \*\*\* `)
+	vendorRe   = regexp.MustCompile(`(?:^|[\s/\[:'"])(vendor/[\w.+-]+)`)
+)
+
+// failedVendorOf is the Vendor of a CompileError in err's chain.
+func failedVendorOf(err error) string {
+	var ce *CompileError
+	if errors.As(err, &ce) {
+		return ce.Vendor
+	}
+	return ""
+}
+
+// failedVendor is the vendored library a build failed in, from the first
+// make line in tail that reports a failed target: make prints the innermost
+// one first, then each make above it. Without such a line, as for cmake's
+// own errors, it is the first error line that names a vendor directory. It
+// is "" when the failure was in TIC-80's own code.
+func failedVendor(tail []string) string {
+	plain := make([]string, len(tail))
+	for i, line := range tail {
+		plain[i] = ansi.Strip(line)
+	}
+	for _, line := range plain {
+		if makeFailRe.MatchString(line) {
+			if m := vendorRe.FindStringSubmatch(line); m != nil {
+				return m[1]
+			}
+			return ""
+		}
+	}
+	for _, line := range plain {
+		if classifyLine(line) != lineError {
+			continue
+		}
+		if m := vendorRe.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// classifyStepError wraps the raw error from running a step in a type
+// picked from the step, or the command it ran, or a DiskFullError.
+func classifyStepError(step installStep, start time.Time, tail []string, err error) error {
+	se := &StepError{Step: step.desc, ExitCode: -1, Output: strings.Join(tail, "\n"), Err: err, Start: start}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		se.ExitCode = exitErr.ExitCode()
+	}
+
+	// A full disk breaks any step, in ways its own errors don't explain.
+	if de := diskFullError(se, tail); de != nil {
+		return de
+	}
+	cmd := step.cmd
+	switch {
+	case isDepsStep(step):
+		return &DependencyError{se}
+	case strings.Contains(cmd, "git clone") || strings.Contains(cmd, "git fetch") || strings.Contains(cmd, "submodule update") || strings.Contains(cmd, "curl "):
+		return &NetworkError{se}
+	case strings.Contains(cmd, "make install") || strings.Contains(cmd, "cmake --install"):
+		return installError(se)
+	case strings.Contains(cmd, "cmake ") || strings.Contains(cmd, "make "):
+		return &CompileError{se, failedVendor(tail)}
+	}
+	return se
+}
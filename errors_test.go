@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFailedVendor(t *testing.T) {
+	tests := []struct {
+		name string
+		tail []string
+		want string
+	}{
+		{"empty", nil, ""},
+		{"make in a vendor target", []string{
+			"/build/TIC-80/vendor/lua/lapi.c:12:10: fatal error: lua.h: No such file or directory",
+			"make[2]: *** [CMakeFiles/lua.dir/build.make:76: CMakeFiles/lua.dir/vendor/lua/lapi.c.o] Error 1",
+			"make[1]: *** [CMakeFiles/Makefile2:1204: CMakeFiles/lua.dir/all] Error 2",
+			"make: *** [Makefile:156: all] Error 2",
+		}, "vendor/lua"},
+		{"make in a vendor subdirectory", []string{
+			"make[2]: *** [vendor/sdl2/CMakeFiles/SDL2-static.dir/build.make:90: vendor/sdl2/CMakeFiles/SDL2-static.dir/src/SDL.c.o] Error 1",
+		}, "vendor/sdl2"},
+		{"cmake error in a vendor", []string{
+			"-- Configuring incomplete, errors occurred!",
+			"CMake Error at vendor/sdl2/CMakeLists.txt:402 (message):",
+			"  SDL could not find X11 or Wayland development libraries",
+		}, "vendor/sdl2"},
+		{"TIC-80's own src", []string{
+			"/build/TIC-80/src/core/core.c:210:5: error: implicit declaration of function 'tic_core_tick'",
+			"make[2]: *** [CMakeFiles/tic80core.dir/build.make:76: CMakeFiles/tic80core.dir/src/core/core.c.o] Error 1",
+			"make[1]: *** [CMakeFiles/Makefile2:1490: CMakeFiles/tic80core.dir/all] Error 2",
+		}, ""},
+		{"the make line wins over an earlier vendor error", []string{
+			"/build/TIC-80/vendor/wren/src/vm/wren_vm.c:5:1: error: unknown type name 'WrenVM'",
+			"make[2]: *** [CMakeFiles/tic80core.dir/build.make:76: CMakeFiles/tic80core.dir/src/api/wren.c.o] Error 1",
+		}, ""},
+		{"ANSI colored make line", []string{
+			"\x1b[01m\x1b[K/build/TIC-80/vendor/wren/src/vm/wren_vm.c:5:1:\x1b[m\x1b[K \x1b[01;31m\x1b[Kerror: \x1b[m\x1b[Kexpected ';'",
+			"\x1b[31mmake[2]: *** [CMakeFiles/wren.dir/build.make:76: CMakeFiles/wren.dir/vendor/wren/src/vm/wren_vm.c.o] Error 1\x1b[0m",
+		}, "vendor/wren"},
+		{"ANSI colored error without make", []string{
+			"\x1b[1mCMake Error at \x1b[0mvendor/zlib/CMakeLists.txt:3 (project):",
+		}, "vendor/zlib"},
+	}
+	for _, tt := range tests {
+		if got := failedVendor(tt.tail); got != tt.want {
+			t.Errorf("%s: failedVendor = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestDependencyErrorAnyPackageManager checks a failed deps step is a
+// DependencyError whichever package manager its command uses.
+func TestDependencyErrorAnyPackageManager(t *testing.T) {
+	for _, cmd := range []string{DEPS_PKGS, DEBIAN_PKGS, "pacman -S --needed --noconfirm base-devel", "zypper -n install -t pattern devel_basis", "xbps-install -Sy base-devel"} {
+		opts := defaultOptions()
+		opts.depsTools, opts.depsPkgs = cmd, cmd
+		for _, step := range depsSteps(opts) {
+			var de *DependencyError
+			if err := classifyStepError(step, time.Now(), nil, errors.New("exit status 1")); !errors.As(err, &de) {
+				t.Errorf("%s, %q: got %T", step.desc, cmd, err)
+			}
+		}
+	}
+	var de *DependencyError
+	if err := classifyStepError(installStep{desc: "Cloning Repository...", cmd: "git clone https://example.org/dnf x"}, time.Now(), nil, errors.New("exit status 128")); errors.As(err, &de) {
+		t.Errorf("clone step: got %T", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// --- COMPILE ETA ---
+
+// ETA_MIN_RUNS is how many timed compiles it takes before there's an
+// estimate; one run alone says little.
+const ETA_MIN_RUNS = 2
+
+// compileTime is how long the compile step of a finished run took, or 0 if
+// it didn't run to completion.
+func compileTime(steps []installStep, durations []time.Duration) time.Duration {
+	for i, step := range steps {
+		if step.desc == "Compiling..." {
+			return durations[i]
+		}
+	}
+	return 0
+}
+
+// incrementalCompile is whether the compile can build on a tree left by an
+// earlier run rather than starting from a fresh clone.
+func incrementalCompile(opts options) bool {
+	return opts.cache || opts.sourceDir != ""
+}
+
+// typicalCompile is the median compile time of past successful runs on this
+// machine that were incremental or clean like this one, or 0 without enough
+// of them.
+func typicalCompile(history []historyEntry, incremental bool) time.Duration {
+	var times []float64
+	for _, e := range history {
+		if e.Success && e.Compile > 0 && e.Incremental == incremental {
+			times = append(times, e.Compile)
+		}
+	}
+	if len(times) < ETA_MIN_RUNS {
+		return 0
+	}
+	return time.Duration(median(times) * float64(time.Second))
+}
+
+// median of xs, which it sorts; 0 for none.
+func median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	slices.Sort(xs)
+	m := xs[len(xs)/2]
+	if len(xs)%2 == 0 {
+		m = (xs[len(xs)/2-1] + m) / 2
+	}
+	return m
+}
+
+// etaStatus is "~4m remaining" while the compile runs and history allows an
+// estimate, otherwise "".
+func (m model) etaStatus() string {
+	if m.medCompile == 0 {
+		return ""
+	}
+	for _, i := range m.runningSteps() {
+		if m.steps[i].desc != "Compiling..." {
+			continue
+		}
+		remaining := m.medCompile - time.Since(m.stepStart[i])
+		if remaining < 0 {
+			return fmt.Sprintf("longer than the usual ~%dm", int(m.medCompile.Round(time.Minute).Minutes()))
+		}
+		if remaining < time.Minute {
+			return "<1m remaining"
+		}
+		return fmt.Sprintf("~%dm remaining", int(remaining.Round(time.Minute).Minutes()))
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypicalCompileComparesLikeRuns(t *testing.T) {
+	history := []historyEntry{
+		{Success: true, Compile: 600},
+		{Success: true, Compile: 620},
+		{Success: true, Compile: 20, Incremental: true},
+		{Success: true, Compile: 30, Incremental: true},
+		{Success: false, Compile: 5, Incremental: true},
+	}
+	if got, want := typicalCompile(history, false), 610*time.Second; got != want {
+		t.Errorf("clean typicalCompile = %v, want %v", got, want)
+	}
+	if got, want := typicalCompile(history, true), 25*time.Second; got != want {
+		t.Errorf("incremental typicalCompile = %v, want %v", got, want)
+	}
+	if got := typicalCompile(history[:3], true); got != 0 {
+		t.Errorf("typicalCompile from one incremental run = %v, want 0", got)
+	}
+}
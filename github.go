@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- GITHUB RATE LIMITS ---
+
+const GITHUB_RATE_LIMIT_URL = "https://api.github.com/rate_limit"
+
+var githubClient = &http.Client{Timeout: 10 * time.Second}
+
+type rateLimitMsg struct {
+	reset time.Time
+}
+
+type rateLimitTickMsg struct{}
+
+// isRateLimited spots GitHub throttling in the output of a failed network
+// step. git doesn't expose response headers, so the reset time is fetched
+// from the API separately.
+func isRateLimited(err error) bool {
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		return false
+	}
+	out := strings.ToLower(netErr.Output)
+	return strings.Contains(out, "rate limit") ||
+		strings.Contains(out, "returned error: 403") ||
+		strings.Contains(out, "returned error: 429")
+}
+
+// fetchRateLimitReset reads X-RateLimit-Reset from the API. GITHUB_TOKEN, if
+// set, authenticates the call so the higher per-user limit applies.
+func fetchRateLimitReset() tea.Cmd {
+	return func() tea.Msg {
+		req, err := http.NewRequest("GET", GITHUB_RATE_LIMIT_URL, nil)
+		if err != nil {
+			return rateLimitMsg{}
+		}
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := githubClient.Do(req)
+		if err != nil {
+			return rateLimitMsg{}
+		}
+		resp.Body.Close()
+		secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return rateLimitMsg{}
+		}
+		return rateLimitMsg{reset: time.Unix(secs, 0)}
+	}
+}
+
+func rateLimitTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return rateLimitTickMsg{} })
+}
+
+func rateLimitNotice(reset time.Time) string {
+	msg := "GitHub rate limit reached."
+	if left := time.Until(reset).Round(time.Second); left > 0 {
+		msg += " Resets in " + left.String() + "."
+	} else if !reset.IsZero() {
+		msg += " The limit has reset, try again."
+	}
+	return msg
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- CPU GOVERNOR ---
+
+const GOVERNOR_FILE = "/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor"
+
+const GOVERNOR_GLOB = "/sys/devices/system/cpu/cpu*/cpufreq/scaling_governor"
+
+// cpuGovernor is cpu0's scaling governor, or "" where cpufreq isn't exposed.
+func cpuGovernor() string {
+	data, err := os.ReadFile(GOVERNOR_FILE)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func governorWarning(opts options) string {
+	if opts.performance || cpuGovernor() != "powersave" {
+		return ""
+	}
+	return "CPU governor is powersave, which slows the compile; --performance switches to performance for the build"
+}
+
+// withPerformanceGovernor switches every CPU to the performance governor for
+// the length of cmd and puts the previous one back however cmd ends.
+func withPerformanceGovernor(cmd string) string {
+	set := func(gov string) string {
+		return fmt.Sprintf("for g in %s; do echo %s > \"$g\"; done", GOVERNOR_GLOB, gov)
+	}
+	return fmt.Sprintf("saved=$(cat %s) && %s && trap '%s' EXIT && trap 'exit 143' TERM && %s",
+		GOVERNOR_FILE, set("performance"), set(`"$saved"`), cmd)
+}
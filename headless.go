@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- HEADLESS RUNNER ---
+
+type logLevel int
+
+const (
+	logQuiet  logLevel = iota // only the final result
+	logNormal                 // step boundaries
+	logDebug                  // full command output
+)
+
+var logLevelNames = map[string]logLevel{
+	"quiet":  logQuiet,
+	"normal": logNormal,
+	"debug":  logDebug,
+}
+
+// runHeadless runs the steps for an operation without the TUI. The log file
+// always gets the full output; level only controls what goes to stdout.
+func runHeadless(a action, opts options) error {
+	if opts.dryRun {
+		// stdout is the script, so these go to stderr.
+		for _, w := range opts.configWarnings {
+			fmt.Fprintln(os.Stderr, "WARNING: "+w)
+		}
+		if a == actionUninstall || a == actionCleanReinstall {
+			printRemovalPreview(opts)
+		}
+		fmt.Print(renderScript(a, opts))
+		return nil
+	}
+
+	steps := getSteps(a, opts)
+	if len(steps) == 0 {
+		err := noStepsError(a)
+		fmt.Printf("FAILED: %v\n", err)
+		return err
+	}
+	ev := runEvents{
+		say: func(text string) { fmt.Println(text) },
+		log: func(line string) {
+			if opts.logLevel >= logDebug {
+				fmt.Println(line)
+			}
+		},
+		step: func(i int, step installStep) {
+			if opts.logLevel >= logNormal {
+				fmt.Printf("[%d/%d] %s\n", i+1, len(steps), step.title())
+			}
+		},
+	}
+	if err := runUnattended(a, opts, steps, ev); err != nil {
+		return err
+	}
+
+	fmt.Println("SUCCESS: Process Completed.")
+	if opts.output != "" && buildsBinary(a) {
+		fmt.Println("Binary copied to " + opts.output)
+	}
+	if buildsBinary(a) && !opts.noInstall {
+		if warning := pathWarning(opts); warning != "" {
+			fmt.Println(warning)
+		}
+	}
+	return nil
+}
+
+// runEvents is how a run without the TUI tells its caller what happens:
+// runHeadless prints, the control socket sends events. Any of them can be
+// nil.
+type runEvents struct {
+	say      func(text string)             // warnings, failures and hints for the user
+	log      func(line string)             // each line as it went to the log file
+	step     func(i int, step installStep) // step i is starting
+	started  func(pid int)                 // its process is running
+	progress func(i int, text string)
+	line     func(i int, msg stepLineMsg)
+	stepDone func(i int, step installStep, err error) // a non-fatal err was a warning
+	stopped  func() bool                              // cancelled: run no more steps
+}
+
+// runUnattended runs steps with the log file, history and report the TUI
+// would write, as runHeadless and the control socket both do.
+func runUnattended(a action, opts options, steps []installStep, ev runEvents) error {
+	start := time.Now()
+	logFile := createLog(opts.logFormat)
+	writeLog := func(line string) {
+		class := classifyLine(ansi.Strip(line))
+		line = formatLogLine(line, opts)
+		logFile.println(line, class)
+		if ev.log != nil {
+			ev.log(line)
+		}
+	}
+
+	for _, line := range logHeader(a, opts) {
+		writeLog(line)
+	}
+	marks := map[int]string{}
+	outputs := newStepOutputs(len(steps), opts)
+	durations := make([]time.Duration, len(steps))
+	failed, err := runHeadlessSteps(a, opts, steps, outputs, durations, marks, writeLog, ev)
+	logFile.Close()
+	end := time.Now()
+	recordHistory(a, opts, start, end, compileTime(steps, durations), err)
+	writeReport(a, opts, steps, outputs, failed, marks, start, end, err)
+	return err
+}
+
+// runHeadlessSteps returns the index of the step that failed (0 for a
+// preflight failure), or len(steps) if all of them ran. Non-fatal failures
+// are recorded in marks, each step's output in outputs and the time each
+// successful step took in durations.
+func runHeadlessSteps(a action, opts options, steps []installStep, outputs []*stepOutput, durations []time.Duration, marks map[int]string, writeLog func(string), ev runEvents) (int, error) {
+	say := func(text string) {
+		if ev.say != nil {
+			ev.say(text)
+		}
+	}
+	stopped := func() bool { return ev.stopped != nil && ev.stopped() }
+
+	warnings, err := preflight(a, opts)
+	for _, w := range warnings {
+		say("WARNING: " + w)
+		writeLog("WARNING: " + w)
+	}
+	if err != nil {
+		say("FAILED: preflight: " + err.Error())
+		return 0, fmt.Errorf("preflight: %w", err)
+	}
+
+	stream := make(chan tea.Msg)
+	// Steps run one at a time here; their order already satisfies dependsOn.
+	for i, step := range steps {
+		if stopped() {
+			return i, errors.New("cancelled")
+		}
+		if ev.step != nil {
+			ev.step(i, step)
+		}
+		writeLog(">>> " + step.desc)
+		stepStart := time.Now()
+		go runStepStreamed(i, step, opts.streams == "separate", stream)()
+
+		var err error
+	wait:
+		for msg := range stream {
+			switch msg := msg.(type) {
+			case stepStartedMsg:
+				if ev.started != nil {
+					ev.started(msg.pid)
+				}
+			case stepProgressMsg:
+				if p := parseProgress(ansi.Strip(msg.text)); p != "" && ev.progress != nil {
+					ev.progress(i, p)
+				}
+			case stepLineMsg:
+				line := msg.tagged(opts)
+				outputs[i].add(line)
+				writeLog(line)
+				if ev.line != nil {
+					ev.line(i, msg)
+				}
+			case stepLogAndFinishMsg:
+				err = msg.err
+				break wait
+			}
+		}
+		if err != nil && stopped() {
+			writeLog("!!! " + step.desc + " cancelled")
+			return i, errors.New("cancelled")
+		}
+		switch {
+		case err != nil && step.nonFatal:
+			say("WARNING: " + err.Error() + ", continuing")
+			writeLog("WARNING: " + err.Error() + ", continuing")
+			marks[i] = "warning"
+		case err != nil:
+			say("FAILED: " + err.Error())
+			if step.desc == "Compiling..." {
+				say("Run --op recompile-verbose to compile again where it stopped; " + VERBOSE_HINT + ".")
+			}
+		default:
+			durations[i] = time.Since(stepStart)
+		}
+		if ev.stepDone != nil {
+			ev.stepDone(i, step, err)
+		}
+		if err != nil && !step.nonFatal {
+			return i, err
+		}
+	}
+	return len(steps), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunUnattendedEvents runs a failing compile through the runner both
+// --headless and the control socket use, and checks what the caller hears.
+func TestRunUnattendedEvents(t *testing.T) {
+	useTempState(t)
+	steps := []installStep{
+		{desc: "Checking...", cmd: "echo checked; exit 1", nonFatal: true, label: "Checking the tree"},
+		{desc: "Compiling...", cmd: "echo compiling; exit 2"},
+		{desc: "Installing...", cmd: "true"},
+	}
+	var said, titles, lines []string
+	var done []error
+	ev := runEvents{
+		say:      func(text string) { said = append(said, text) },
+		step:     func(i int, step installStep) { titles = append(titles, step.title()) },
+		line:     func(i int, msg stepLineMsg) { lines = append(lines, msg.text) },
+		stepDone: func(i int, step installStep, err error) { done = append(done, err) },
+	}
+	err := runUnattended(actionUninstall, defaultOptions(), steps, ev)
+	if err == nil {
+		t.Fatal("the failed compile wasn't returned")
+	}
+	if len(titles) != 2 || titles[0] != steps[0].title() {
+		t.Errorf("steps started %q, want the first two by title", titles)
+	}
+	if strings.Join(lines, ",") != "checked,compiling" {
+		t.Errorf("lines %q", lines)
+	}
+	if len(done) != 2 || done[0] == nil || done[1] == nil {
+		t.Errorf("step_done errors %v", done)
+	}
+	joined := strings.Join(said, "\n")
+	for _, want := range []string{", continuing", "FAILED: ", "--op recompile-verbose", VERBOSE_HINT} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("said %q, missing %q", joined, want)
+		}
+	}
+	if !fileExists(REPORT_FILE) || !fileExists(HISTORY_FILE) {
+		t.Error("no report or history written")
+	}
+}
+
+// TestRunUnattendedStops checks a cancel before a step keeps it from running.
+func TestRunUnattendedStops(t *testing.T) {
+	useTempState(t)
+	ran := false
+	ev := runEvents{
+		step:    func(i int, step installStep) { ran = true },
+		stopped: func() bool { return true },
+	}
+	err := runUnattended(actionUninstall, defaultOptions(), []installStep{{desc: "Testing...", cmd: "true"}}, ev)
+	if err == nil || err.Error() != "cancelled" || ran {
+		t.Fatalf("err %v, ran %v", err, ran)
+	}
+}
+
+// TestControlShutdownWaits checks the server doesn't return while a run is
+// still writing its history and report.
+func TestControlShutdownWaits(t *testing.T) {
+	s := &controlServer{running: true, finished: make(chan struct{})}
+	returned := make(chan struct{})
+	go func() {
+		s.shutdown()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("shutdown returned with the run still going")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(s.finished)
+	<-returned
+	if !s.closing {
+		t.Error("shutdown left the server taking new runs")
+	}
+}
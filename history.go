@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- BUILD HISTORY ---
+
+var STATE_DIR = STATE_HOME
+
+var (
+	HISTORY_FILE = filepath.Join(STATE_DIR, "history.jsonl")
+	HISTORY_LOGS = filepath.Join(STATE_DIR, "logs")
+)
+
+// historyEntry is one line of HISTORY_FILE.
+type historyEntry struct {
+	Start    time.Time `json:"start"`
+	Op       string    `json:"op"`
+	Duration float64   `json:"duration_s"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Ref      string    `json:"ref,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	Compile  float64   `json:"compile_s,omitempty"` // the compile step alone, for the ETA
+	Log      string    `json:"log,omitempty"`
+
+	// Incremental is set when the compile may have reused an earlier tree
+	// (--cache or --source-dir), so its time isn't comparable to a clean one.
+	Incremental bool `json:"incremental,omitempty"`
+
+	// Measured by --benchmark only.
+	Jobs      int      `json:"jobs,omitempty"`
+	CPU       float64  `json:"cpu_s,omitempty"`
+	PeakKB    int64    `json:"peak_rss_kb,omitempty"`
+	CacheHits *float64 `json:"ccache_hit_rate,omitempty"`
+}
+
+// recordHistory keeps a copy of the run's log and appends an entry for it;
+// compile is the time the compile step took, 0 if it didn't complete.
+// History is best effort: a failure here never fails the run.
+func recordHistory(a action, opts options, start, end time.Time, compile time.Duration, runErr error) {
+	entry := historyEntry{
+		Start:       start,
+		Op:          operationNames[a],
+		Duration:    end.Sub(start).Round(time.Second).Seconds(),
+		Success:     runErr == nil,
+		Ref:         opts.ref,
+		Label:       opts.label,
+		Compile:     compile.Round(time.Second).Seconds(),
+		Incremental: incrementalCompile(opts),
+	}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	appendHistory(entry, start)
+}
+
+// appendHistory copies the log for entry and appends it to HISTORY_FILE.
+func appendHistory(entry historyEntry, start time.Time) {
+	if err := os.MkdirAll(HISTORY_LOGS, 0755); err != nil {
+		return
+	}
+	logCopy := filepath.Join(HISTORY_LOGS, start.Format("20060102-150405")+".log")
+	if copyFile(LOG_FILE, logCopy) == nil {
+		entry.Log = logCopy
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(HISTORY_FILE, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// loadHistory returns the recorded runs, newest first. Lines that don't parse
+// are skipped.
+func loadHistory() []historyEntry {
+	f, err := os.Open(HISTORY_FILE)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append([]historyEntry{e}, entries...)
+		}
+	}
+	return entries
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (e historyEntry) row(timeFormat string) string {
+	result := styleSuccess.Render("OK  ")
+	if !e.Success {
+		result = styleError.Render("FAIL")
+	}
+	ref := e.Ref
+	if ref == "" {
+		ref = "default"
+	}
+	duration := (time.Duration(e.Duration) * time.Second).String()
+	row := styleNormal.Render(fmt.Sprintf("%-16s  %-9s %8s", formatTime(e.Start.Local(), timeFormat, false), e.Op, duration)) +
+		result + styleNormal.Render(ref)
+	if e.Label != "" {
+		row += styleLog.Render("  " + e.Label)
+	}
+	return row
+}
+
+// renderHistory shows as many entries around the cursor as fit in height.
+func renderHistory(entries []historyEntry, cursor, height int, timeFormat string) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Recent Builds") + "\n\n")
+	if len(entries) == 0 {
+		s.WriteString(" " + styleLog.Render("No builds recorded yet.") + "\n")
+	}
+	if height < 1 {
+		height = 1
+	}
+	first := 0
+	if cursor >= height {
+		first = cursor - height + 1
+	}
+	for i := first; i < len(entries) && i < first+height; i++ {
+		if i == cursor {
+			s.WriteString(" " + styleError.Render(">█ ") + entries[i].row(timeFormat) + "\n")
+		} else {
+			s.WriteString("    " + entries[i].row(timeFormat) + "\n")
+		}
+	}
+	s.WriteString("\n " + styleLog.Render("Enter: view log  Esc: back"))
+	return s.String()
+}
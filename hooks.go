@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// --- HOOKS ---
+
+// hookStep runs a user script with the install described in its
+// environment. TIC80_VERSION is read from the installed binary with an ERE
+// spelling of versionRe, like probeVersion's fallback, and is empty before
+// an install.
+func hookStep(desc, script string, a action, opts options) installStep {
+	bin := binPath(opts.prefix)
+	return installStep{
+		desc: desc,
+		cmd: fmt.Sprintf("TIC80_OP=%s TIC80_PREFIX=%s TIC80_BINARY=%s TIC80_VERSION=$(grep -aoE -m1 '[0-9]+\\.[0-9]+\\.[0-9]{3,}' %s 2>/dev/null | head -1) %s",
+			operationNames[a], shellQuote(opts.prefix), shellQuote(bin), shellQuote(bin), shellQuote(script)),
+	}
+}
+
+// withHooks wraps the steps of a with the hooks that apply to it.
+func withHooks(a action, opts options, steps []installStep) []installStep {
+	switch a {
+	case actionInstall, actionUpgrade, actionInstallExisting, actionCleanReinstall:
+		if opts.preInstallHook != "" {
+			pre := hookStep("Running pre-install hook...", opts.preInstallHook, a, opts)
+			for i := range steps {
+				// Steps free to start at once now wait for the hook.
+				if steps[i].dependsOn != nil && len(steps[i].dependsOn) == 0 {
+					steps[i].dependsOn = []string{pre.desc}
+				}
+			}
+			steps = append([]installStep{pre}, steps...)
+		}
+		if opts.postInstallHook != "" && !opts.noInstall {
+			steps = append(steps, hookStep("Running post-install hook...", opts.postInstallHook, a, opts))
+		}
+	case actionUninstall:
+		if opts.postUninstallHook != "" {
+			steps = append(steps, hookStep("Running post-uninstall hook...", opts.postUninstallHook, a, opts))
+		}
+	}
+	return steps
+}
+
+// checkHooks fails preflight on a hook that can't run, rather than after the
+// build it was meant to follow.
+func checkHooks(opts options) error {
+	for flag, script := range map[string]string{
+		"--pre-install-hook":    opts.preInstallHook,
+		"--post-install-hook":   opts.postInstallHook,
+		"--post-uninstall-hook": opts.postUninstallHook,
+	} {
+		if script == "" {
+			continue
+		}
+		info, err := os.Stat(script)
+		if err != nil {
+			return fmt.Errorf("%s: %v", flag, err)
+		}
+		if info.Mode()&0111 == 0 {
+			return fmt.Errorf("%s: %s is not executable", flag, script)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// --- BUILD JOBS ---
+
+// A C++ compile job in TIC-80 can peak around 2 GiB.
+const MEM_PER_JOB = 2 << 30
+
+// memAvailable reads MemAvailable plus free swap from /proc/meminfo, in
+// bytes; ok is false if it couldn't be read.
+func memAvailable() (uint64, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	var total uint64
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || (fields[0] != "MemAvailable:" && fields[0] != "SwapFree:") {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		total += kb << 10
+		found = found || fields[0] == "MemAvailable:"
+	}
+	return total, found
+}
+
+// recommendedJobs is how many compile jobs memory allows, capped at the core
+// count; 0 if memory couldn't be read.
+func recommendedJobs() int {
+	mem, ok := memAvailable()
+	if !ok {
+		return 0
+	}
+	// A container sees the host's meminfo but is held to its cgroup's limit.
+	if limit, ok := cgroupMemLimit(); ok && limit < mem {
+		mem = limit
+	}
+	return min(runtime.NumCPU(), max(1, int(mem/MEM_PER_JOB)))
+}
+
+// jobCount is the -j value the compile step will use.
+func jobCount(opts options) int {
+	switch opts.jobs {
+	case "":
+		return runtime.NumCPU()
+	case "auto":
+		if n := recommendedJobs(); n > 0 {
+			return n
+		}
+		return runtime.NumCPU()
+	}
+	n, _ := strconv.Atoi(opts.jobs)
+	return n
+}
+
+// jobsArg is what goes after make -j. Without --jobs it stays $(nproc) so an
+// exported script adapts to the machine it runs on.
+func jobsArg(opts options) string {
+	if opts.jobs == "" {
+		return "$(nproc)"
+	}
+	return strconv.Itoa(jobCount(opts))
+}
+
+func validJobs(jobs string) bool {
+	if jobs == "" || jobs == "auto" {
+		return true
+	}
+	n, err := strconv.Atoi(jobs)
+	return err == nil && n > 0
+}
+
+// jobsWarning is set when the job count is likely to exhaust memory.
+func jobsWarning(opts options) string {
+	rec := recommendedJobs()
+	if rec == 0 || jobCount(opts) <= rec {
+		return ""
+	}
+	mem, _ := memAvailable()
+	return fmt.Sprintf("make -j%d may run out of memory (%.1f GiB available, about 2 GiB per job); try --jobs %d or --jobs auto",
+		jobCount(opts), float64(mem)/(1<<30), rec)
+}
+
+func jobsSummary(opts options) string {
+	s := strconv.Itoa(jobCount(opts))
+	switch opts.jobs {
+	case "":
+		s += " (nproc)"
+	case "auto":
+		s += " (capped by memory)"
+	}
+	if rec := recommendedJobs(); rec > 0 && rec < jobCount(opts) {
+		s += fmt.Sprintf(", memory suggests %d", rec)
+	}
+	return s
+}
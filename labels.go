@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// --- STEP LABELS ---
+
+// stepVars is what a step label template can use, e.g.
+// "Compiling TIC-80 {{.Ref}} with {{.Jobs}} jobs...".
+type stepVars struct {
+	Op     string
+	Ref    string // "" for the default branch
+	Distro string
+	Jobs   string
+	Prefix string
+}
+
+func labelVars(choice action, opts options) stepVars {
+	return stepVars{
+		Op:     operationNames[choice],
+		Ref:    opts.ref,
+		Distro: distroKey(),
+		Jobs:   strconv.Itoa(jobCount(opts)),
+		Prefix: opts.prefix,
+	}
+}
+
+// parseStepLabel splits a --step-label DESC=TEMPLATE.
+func parseStepLabel(s string) (desc, label string, err error) {
+	desc, label, ok := strings.Cut(s, "=")
+	if !ok || desc == "" {
+		return "", "", fmt.Errorf("--step-label %q is not DESC=TEMPLATE", s)
+	}
+	if _, err := template.New(desc).Parse(label); err != nil {
+		return "", "", fmt.Errorf("--step-label %q: %v", s, err)
+	}
+	return desc, label, nil
+}
+
+// renderLabels applies --step-label over the built-in labels and renders
+// them; a template that fails leaves the plain desc.
+func renderLabels(choice action, opts options, steps []installStep) []installStep {
+	custom := map[string]string{}
+	for _, s := range opts.stepLabels {
+		if desc, label, err := parseStepLabel(s); err == nil {
+			custom[desc] = label
+		}
+	}
+	vars := labelVars(choice, opts)
+	for i := range steps {
+		if label, ok := custom[steps[i].desc]; ok {
+			steps[i].label = label
+		}
+		if steps[i].label == "" {
+			continue
+		}
+		var s strings.Builder
+		t, err := template.New(steps[i].desc).Parse(steps[i].label)
+		if err == nil {
+			err = t.Execute(&s, vars)
+		}
+		steps[i].label = ""
+		if err == nil {
+			steps[i].label = s.String()
+		}
+	}
+	return steps
+}
+
+// title is the step as shown while it runs.
+func (step installStep) title() string {
+	if step.label != "" {
+		return step.label
+	}
+	return step.desc
+}
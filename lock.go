@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// --- SINGLE INSTANCE ---
+
+var LOCK_FILE = "/run/" + APP_NAME + ".lock"
+
+// LOCK_FD_ENV tells a --detach child which inherited descriptor is the lock
+// its parent took, so no other launch can get it in between.
+const LOCK_FD_ENV = "TIC80_MANAGER_LOCK_FD"
+
+// instanceLock holds LOCK_FILE open for as long as we run. The kernel drops
+// the flock when the process goes, however it goes, so a crash or kill -9
+// never leaves it stuck; only the PID written in it stays behind.
+var instanceLock *os.File
+
+// lockInstance takes LOCK_FILE, or says who has it: two runs building into
+// the same tree as root would wreck each other's checkout and build. Taking
+// it again while holding it is a no-op.
+func lockInstance() error {
+	if instanceLock != nil {
+		return nil
+	}
+	if f := inheritedLock(); f != nil {
+		f.Truncate(0)
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		instanceLock = f
+		return nil
+	}
+	f, err := os.OpenFile(LOCK_FILE, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open the lock file: %v", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		data, _ := io.ReadAll(f)
+		f.Close()
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("cannot lock %s: %v", LOCK_FILE, err)
+		}
+		holder := "another tic80-manager"
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			holder += fmt.Sprintf(" (PID %d)", pid)
+		}
+		return fmt.Errorf("%s is already running. Wait for it to finish; a build started with --detach can be followed with --attach PID", holder)
+	}
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	instanceLock = f
+	return nil
+}
+
+// inheritedLock is the lock a --detach parent passed down, once it is sure
+// to still hold it: flock on the same open file succeeds, on another
+// fails. The steps we run don't inherit it.
+func inheritedLock() *os.File {
+	fd, err := strconv.Atoi(os.Getenv(LOCK_FD_ENV))
+	os.Unsetenv(LOCK_FD_ENV)
+	if err != nil {
+		return nil
+	}
+	f := os.NewFile(uintptr(fd), LOCK_FILE)
+	if f == nil || syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		return nil
+	}
+	syscall.CloseOnExec(fd)
+	return f
+}
+
+// unlockInstance gives the lock up when a TUI run ends.
+func unlockInstance() {
+	if instanceLock != nil {
+		instanceLock.Close()
+		instanceLock = nil
+	}
+}
+
+// needsLock is whether a launch writes from the start, and so has to be the
+// only one: headless, compact, detached, benchmark and control socket runs,
+// but not a headless dry run. The TUI only takes the lock while it runs
+// steps (see startRun), so its log viewer can follow a headless build.
+func needsLock(opts options) bool {
+	if opts.attach != 0 || opts.headless && opts.dryRun {
+		return false
+	}
+	return opts.headless || opts.compact || opts.detach || opts.benchmark || opts.controlSocket != ""
+}
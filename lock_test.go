@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func useTempLock(t *testing.T) {
+	old := LOCK_FILE
+	LOCK_FILE = t.TempDir() + "/test.lock"
+	t.Cleanup(func() {
+		unlockInstance()
+		LOCK_FILE = old
+	})
+}
+
+func TestNeedsLock(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*options)
+		want bool
+	}{
+		{"tui", func(o *options) {}, false},
+		{"headless", func(o *options) { o.headless = true }, true},
+		{"headless dry run", func(o *options) { o.headless, o.dryRun = true, true }, false},
+		{"compact", func(o *options) { o.compact = true }, true},
+		{"detach", func(o *options) { o.detach = true }, true},
+		{"attach", func(o *options) { o.attach = 1 }, false},
+		{"benchmark", func(o *options) { o.benchmark = true }, true},
+		{"control socket", func(o *options) { o.controlSocket = "/tmp/s" }, true},
+	}
+	for _, tt := range tests {
+		opts := defaultOptions()
+		tt.set(&opts)
+		if got := needsLock(opts); got != tt.want {
+			t.Errorf("%s: needsLock = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestRunTakesLock checks the TUI holds the lock only while a run goes, and
+// refuses to start one while another process has it.
+func TestRunTakesLock(t *testing.T) {
+	useTempState(t)
+	useTempLock(t)
+	m := initialModel(defaultOptions())
+	m.steps = []installStep{{desc: "Testing...", cmd: "true"}}
+
+	other, err := os.OpenFile(LOCK_FILE, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	m.startRun()
+	if m.state != stateDone || m.err == nil || !strings.Contains(m.err.Error(), "already running") {
+		t.Fatalf("started with the lock taken: state %v, err %v", m.state, m.err)
+	}
+	other.Close()
+
+	m.startRun()
+	if m.state != stateRunning || instanceLock == nil {
+		t.Fatalf("run didn't start or take the lock: state %v, err %v", m.state, m.err)
+	}
+	m.finishRun(nil)
+	if instanceLock != nil {
+		t.Fatal("lock still held after the run")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- LOG FORMATS ---
+
+// plain strips escape codes, ansi keeps them and colors error and warning
+// lines, html is a page with the same colors as the TUI.
+var LOG_FORMATS = []string{"plain", "ansi", "html"}
+
+const HTML_LOG_HEAD = "<!DOCTYPE html>"
+
+// logWriter writes LOG_FILE in one of LOG_FORMATS. A nil *logWriter, for a
+// log that couldn't be created, drops every line.
+type logWriter struct {
+	f      *os.File
+	format string
+}
+
+// A missing log file shouldn't stop the run, so errors only leave it nil.
+func createLog(format string) *logWriter {
+	f, err := os.Create(LOG_FILE)
+	if err != nil {
+		return nil
+	}
+	if format == "html" {
+		fmt.Fprintf(f, "%s\n<html><head><meta charset=\"utf-8\"><title>tic80-manager log</title><style>\n"+
+			"body { background: %s; color: %s; }\n.error { color: %s; font-weight: bold; }\n.warning { color: %s; }\n"+
+			"</style></head><body><pre>\n", HTML_LOG_HEAD, ColorVoid.TrueColor, ColorWhite.TrueColor, ColorRed.TrueColor, ColorYellow.TrueColor)
+	}
+	return &logWriter{f: f, format: format}
+}
+
+// sgr is the escape code that sets c as the foreground color. Log files
+// aren't tied to a terminal, so they always get the truecolor value.
+func sgr(c lipgloss.CompleteColor) string {
+	var r, g, b int
+	fmt.Sscanf(c.TrueColor, "#%02x%02x%02x", &r, &g, &b)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+}
+
+// println writes line, already timestamped, classified from before it was,
+// and forwards it to --syslog, even when the file couldn't be created.
+func (w *logWriter) println(line string, class lineClass) {
+	remoteLog.send(line, class)
+	if w == nil {
+		return
+	}
+	switch w.format {
+	case "ansi":
+		switch class {
+		case lineError:
+			line = "\x1b[1m" + sgr(ColorRed) + line + "\x1b[0m"
+		case lineWarning:
+			line = sgr(ColorYellow) + line + "\x1b[0m"
+		}
+	case "html":
+		line = html.EscapeString(ansi.Strip(line))
+		switch class {
+		case lineError:
+			line = `<span class="error">` + line + "</span>"
+		case lineWarning:
+			line = `<span class="warning">` + line + "</span>"
+		}
+	default:
+		line = ansi.Strip(line)
+	}
+	fmt.Fprintln(w.f, line)
+}
+
+func (w *logWriter) Close() {
+	if w == nil {
+		return
+	}
+	if w.format == "html" {
+		fmt.Fprintln(w.f, "</pre></body></html>")
+	}
+	w.f.Close()
+}
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// logText turns an HTML log back into its lines for the log viewer; other
+// logs are shown as they are.
+func logText(data string) string {
+	if !strings.HasPrefix(data, HTML_LOG_HEAD) {
+		return data
+	}
+	_, body, _ := strings.Cut(data, "<pre>\n")
+	body, _, _ = strings.Cut(body, "</pre>")
+	return html.UnescapeString(htmlTagRe.ReplaceAllString(body, ""))
+}
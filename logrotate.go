@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- LOG ROTATION ---
+
+// Ages, in days, at which the per-run logs in HISTORY_LOGS are gzipped and
+// then deleted.
+const (
+	DEFAULT_LOG_COMPRESS_DAYS = 7
+	DEFAULT_LOG_KEEP_DAYS     = 90
+)
+
+// rotation is what one pass of rotateLogs did.
+type rotation struct {
+	compressed int
+	deleted    int
+	freed      int64 // bytes
+}
+
+func (r rotation) String() string {
+	if r.compressed == 0 && r.deleted == 0 {
+		return "No logs old enough to compress or delete."
+	}
+	return fmt.Sprintf("Compressed %d and deleted %d old logs, reclaiming %.1f MiB.", r.compressed, r.deleted, float64(r.freed)/(1<<20))
+}
+
+type logsCleanedMsg struct {
+	rotation rotation
+	err      error
+}
+
+// cleanLogs is Clean Logs in the menu.
+func cleanLogs(opts options) tea.Cmd {
+	return func() tea.Msg {
+		r, err := rotateLogs(opts)
+		return logsCleanedMsg{rotation: r, err: err}
+	}
+}
+
+// rotateMu keeps the rotation at startup and Clean Logs from compressing the
+// same log at once.
+var rotateMu sync.Mutex
+
+// rotateLogs gzips the run logs older than --log-compress-days and deletes
+// those older than --log-keep-days; 0 turns either off. A log's age is its
+// mtime, which compressing keeps. History entries keep pointing at the .log
+// name, the log viewer finds the .gz next to it.
+func rotateLogs(opts options) (rotation, error) {
+	rotateMu.Lock()
+	defer rotateMu.Unlock()
+	var r rotation
+	entries, err := os.ReadDir(HISTORY_LOGS)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return r, err
+	}
+	now := time.Now()
+	olderThan := func(fi os.FileInfo, days int) bool {
+		return days > 0 && now.Sub(fi.ModTime()) > time.Duration(days)*24*time.Hour
+	}
+	for _, e := range entries {
+		path := filepath.Join(HISTORY_LOGS, e.Name())
+		fi, err := e.Info()
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(e.Name(), ".tmp"):
+			// Left by a compression that was cut short, unless another run
+			// is still writing it.
+			if now.Sub(fi.ModTime()) > time.Hour {
+				os.Remove(path)
+			}
+		case strings.HasSuffix(e.Name(), ".log") || strings.HasSuffix(e.Name(), ".log.gz"):
+			if olderThan(fi, opts.logKeepDays) {
+				if os.Remove(path) == nil {
+					r.deleted++
+					r.freed += fi.Size()
+				}
+			} else if strings.HasSuffix(e.Name(), ".log") && olderThan(fi, opts.logGzipDays) {
+				if fileExists(path + ".gz") {
+					// Compressed by another copy of the tool that hasn't
+					// removed the original yet.
+					continue
+				}
+				size, err := gzipFile(path, fi.ModTime())
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return r, err
+				}
+				r.compressed++
+				r.freed += fi.Size() - size
+			}
+		}
+	}
+	return r, nil
+}
+
+// gzipFile replaces path with path.gz, dated mtime, and returns its size. The
+// .gz only appears once it is complete, from a temporary file of its own, so
+// two processes compressing the same log can't write into each other's.
+func gzipFile(path string, mtime time.Time) (int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".gz.*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmp := out.Name()
+	err = out.Chmod(0644)
+	zw := gzip.NewWriter(out)
+	if err == nil {
+		_, err = io.Copy(zw, in)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, mtime, mtime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("compressing %s: %v", path, err)
+	}
+	os.Remove(path)
+	fi, err := os.Stat(path + ".gz")
+	if err != nil {
+		return 0, nil
+	}
+	return fi.Size(), nil
+}
+
+// readGzipLog is the text of path.gz, for a log rotateLogs compressed.
+func readGzipLog(path string) (string, error) {
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(zr)
+	return string(data), err
+}
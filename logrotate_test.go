@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRotateLogsConcurrent runs the startup rotation and Clean Logs at once
+// and checks every log comes out as one whole .gz.
+func TestRotateLogsConcurrent(t *testing.T) {
+	useTempState(t)
+	if err := os.MkdirAll(HISTORY_LOGS, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	want := map[string]string{}
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(HISTORY_LOGS, fmt.Sprintf("run-%02d.log", i))
+		text := strings.Repeat(fmt.Sprintf("line of log %d\n", i), 5000)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, old, old)
+		want[path] = text
+	}
+	opts := defaultOptions()
+	opts.logGzipDays, opts.logKeepDays = 7, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rotateLogs(opts); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for path, text := range want {
+		if fileExists(path) {
+			t.Errorf("%s left uncompressed", path)
+		}
+		got, err := readGzipLog(path)
+		if err != nil || got != text {
+			t.Errorf("%s.gz: %d bytes, want %d (%v)", path, len(got), len(text), err)
+		}
+	}
+	tmps, _ := filepath.Glob(filepath.Join(HISTORY_LOGS, "*.tmp"))
+	if len(tmps) > 0 {
+		t.Errorf("temporary files left: %q", tmps)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- LOG VIEWER ---
+
+const LOG_FOLLOW_INTERVAL = 500 * time.Millisecond
+
+// logChunkMsg carries whatever was appended to the log since the last read.
+// reset means the file was truncated or replaced and data starts from zero.
+type logChunkMsg struct {
+	data  string
+	pos   int64
+	ino   uint64
+	reset bool
+	err   error
+}
+
+type logFollowTickMsg struct {
+	gen int
+}
+
+// openLogView shows the log at path; Esc returns to back.
+func (m *model) openLogView(path string, back state) tea.Cmd {
+	m.state = stateLogView
+	m.logPath, m.logBack = path, back
+	m.logView, m.logPos, m.logIno = "", 0, 0
+	m.logFollow = false
+	m.layoutViewport()
+	m.viewport.SetContent("")
+	return readLogChunk(path, 0, 0)
+}
+
+// readLogChunk reads path from pos onwards. If the inode changed or the file
+// shrank below pos, it was rotated or truncated and is re-read whole.
+func readLogChunk(path string, pos int64, ino uint64) tea.Cmd {
+	return func() tea.Msg {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) && fileExists(path+".gz") {
+			// An old run's log, compressed by rotateLogs: shown whole.
+			data, err := readGzipLog(path)
+			return logChunkMsg{data: data, pos: int64(len(data)), reset: true, err: err}
+		}
+		if err != nil {
+			return logChunkMsg{err: err}
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return logChunkMsg{err: err}
+		}
+		var newIno uint64
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			newIno = st.Ino
+		}
+		reset := false
+		if newIno != ino || fi.Size() < pos {
+			pos = 0
+			reset = true
+		}
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return logChunkMsg{err: err}
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return logChunkMsg{err: err}
+		}
+		return logChunkMsg{data: string(data), pos: pos + int64(len(data)), ino: newIno, reset: reset}
+	}
+}
+
+func logFollowTick(gen int) tea.Cmd {
+	return tea.Tick(LOG_FOLLOW_INTERVAL, func(time.Time) tea.Msg { return logFollowTickMsg{gen: gen} })
+}
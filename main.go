@@ -1,31 +1,43 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 // --- TIC-80 DB16 PALETTE ---
+// Each color is hand-mapped to the nearest xterm-256 entry and to one of the
+// 16 ANSI colors, which lipgloss picks by the terminal's color profile.
 var (
-	ColorVoid   = lipgloss.Color("#140c1c")
-	ColorPurple = lipgloss.Color("#442434")
-	ColorBlue   = lipgloss.Color("#30346d")
-	ColorGrey   = lipgloss.Color("#4e4a4e")
-	ColorBrown  = lipgloss.Color("#854c30")
-	ColorGreen  = lipgloss.Color("#346524")
-	ColorRed    = lipgloss.Color("#d04648")
-	ColorWhite  = lipgloss.Color("#deeed6")
+	ColorVoid   = lipgloss.CompleteColor{TrueColor: "#140c1c", ANSI256: "233", ANSI: "0"}
+	ColorPurple = lipgloss.CompleteColor{TrueColor: "#442434", ANSI256: "53", ANSI: "5"}
+	ColorBlue   = lipgloss.CompleteColor{TrueColor: "#30346d", ANSI256: "60", ANSI: "4"}
+	ColorGrey   = lipgloss.CompleteColor{TrueColor: "#4e4a4e", ANSI256: "239", ANSI: "8"}
+	ColorBrown  = lipgloss.CompleteColor{TrueColor: "#854c30", ANSI256: "94", ANSI: "3"}
+	ColorGreen  = lipgloss.CompleteColor{TrueColor: "#346524", ANSI256: "22", ANSI: "2"}
+	ColorRed    = lipgloss.CompleteColor{TrueColor: "#d04648", ANSI256: "167", ANSI: "1"}
+	ColorWhite  = lipgloss.CompleteColor{TrueColor: "#deeed6", ANSI256: "254", ANSI: "15"}
+	ColorYellow = lipgloss.CompleteColor{TrueColor: "#dad45e", ANSI256: "185", ANSI: "11"}
 	
-	RainbowColors = []lipgloss.Color{
-		lipgloss.Color("#d04648"), lipgloss.Color("#d27d2c"), lipgloss.Color("#dad45e"),
-		lipgloss.Color("#6daa2c"), lipgloss.Color("#597dce"), lipgloss.Color("#574290"),
+	RainbowColors = []lipgloss.CompleteColor{
+		ColorRed, {TrueColor: "#d27d2c", ANSI256: "172", ANSI: "3"}, ColorYellow,
+		{TrueColor: "#6daa2c", ANSI256: "70", ANSI: "10"}, {TrueColor: "#597dce", ANSI256: "68", ANSI: "12"}, {TrueColor: "#574290", ANSI256: "61", ANSI: "13"},
 	}
 
 	// --- STYLES ---
@@ -45,26 +57,142 @@ var (
 		Background(ColorVoid).
 		Padding(0, 1)
 
-	styleTermText = lipgloss.NewStyle().Foreground(lipgloss.Color("#666666"))
+	styleTermText = lipgloss.NewStyle().Foreground(lipgloss.CompleteColor{TrueColor: "#666666", ANSI256: "241", ANSI: "8"})
+	styleTermErr  = lipgloss.NewStyle().Foreground(ColorBrown)
+	styleInfo     = lipgloss.NewStyle().Foreground(RainbowColors[4])
 )
 
 const DEPS_CMD = "dnf -y install @development-tools"
 const DEPS_PKGS = "dnf -y install gcc gcc-c++ cmake ruby rubygem-rake libglvnd-devel libglvnd-gles freeglut-devel alsa-lib-devel git libX11-devel libXext-devel libXcursor-devel libXi-devel libXrandr-devel mesa-libGLU-devel curl"
 
+const DEFAULT_PREFIX = "/usr/local"
+
+// SDL2 tag checked out over the vendored copy with --patch-sdl, unless
+// --sdl-version says otherwise.
+const DEFAULT_SDL_VERSION = "release-2.32.8"
+
+var sdlVersionRe = regexp.MustCompile(`^(pre)?release-\d+\.\d+\.\d+$`)
+
+// Build tree, under the XDG cache dir for anyone but root (see xdg.go).
+var BUILD_DIR = buildDir()
+
+// Where the TIC-80 source is cloned to.
+var SRC_DIR = BUILD_DIR + "/TIC-80"
+
+// Written by the reproducible-build step, read back by configure and compile.
+var EPOCH_FILE = BUILD_DIR + "/SOURCE_DATE_EPOCH"
+
+// Full output of the last run, overwritten each time.
+var LOG_FILE = logPath()
+
+// Read when --config isn't given, if it exists.
+var DEFAULT_CONFIG = filepath.Join(CONFIG_DIR, "config.json")
+
+// Below this size the layout overflows and the altscreen garbles.
+const MIN_WIDTH = 60
+const MIN_HEIGHT = 15
+
 type installStep struct {
 	desc string
 	cmd  string
+	// dependsOn names the earlier steps (by desc) this one waits for, so it
+	// can run alongside the rest. nil waits for every earlier step.
+	dependsOn []string
+	// nonFatal steps only warn when they fail; the run carries on.
+	nonFatal bool
+	// label, if set, is a template shown instead of desc (see
+	// renderLabels); desc stays the name dependsOn and hooks go by.
+	label string
 }
 
 func renderRainbow(text string) string {
+	// An empty palette would divide by zero below.
+	if len(RainbowColors) == 0 {
+		return lipgloss.NewStyle().Foreground(ColorWhite).Background(ColorVoid).Render(text)
+	}
 	var s strings.Builder
-	for i, char := range text {
+	i := 0 // rune count; range yields byte offsets
+	for _, char := range text {
 		color := RainbowColors[i%len(RainbowColors)]
+		i++
 		s.WriteString(lipgloss.NewStyle().Foreground(color).Background(ColorVoid).Render(string(char)))
 	}
 	return s.String()
 }
 
+// options holds the command-line flags.
+type options struct {
+	timestamps     bool
+	timeFormat     string
+	installService bool
+	installDemos   bool
+	registerMime   bool
+	serviceScope   string
+	serviceArgs    string
+	exportScript   string
+	exportDocker   string
+	baseImage      string
+	op             string
+	headless       bool
+	dryRun         bool
+	prefix         string
+	compact        bool
+	sdlVersion     string
+	renderer       string
+	patchSDL       bool
+	sandbox        string
+	wrapper        string
+	streams        string
+	logFormat      string
+	colorProfile   string
+	syslog         string
+	detach         bool
+	attach         int
+	controlSocket  string
+	reproducible   bool
+	cache          bool
+	cacheDir       string
+	vendorCache    bool
+	keepBuild      bool
+	output         string
+	noInstall      bool
+	jobs           string
+	submoduleJobs  int
+	inline         bool
+	autoLog        bool
+	allowRoot      bool
+	benchmark      bool
+	runs           int
+	sourceDir      string
+	configWarnings []string // from loading the config file, shown at preflight
+	performance    bool
+	scrollback     int
+	reportHead     int
+	reportTail     int
+	logGzipDays    int
+	logKeepDays    int
+	ref            string
+	label          string
+	depsTools      string
+	depsPkgs       string
+	cmakeFlags     stringList
+	stepLabels     stringList
+	mirrors        stringList
+	preset         string
+	presets        map[string]preset // from --config, on top of the built-in ones
+	explicit       map[string]bool   // set on the command line or in --config, which presets leave alone
+	logLevel       logLevel
+
+	// Scripts run around installs and uninstalls, see withHooks.
+	preInstallHook    string
+	postInstallHook   string
+	postUninstallHook string
+
+	// See verifySignatureStep.
+	verifySignature bool
+	trustedKeys     string
+}
+
 // --- MODEL ---
 type state int
 
@@ -72,52 +200,358 @@ const (
 	stateMenu state = iota
 	stateRunning
 	stateDone
+	stateExportPick
+	stateSummary
+	stateLogView
+	stateHistory
+	statePresetPick
+	stateSettings
+	stateStepList
+	stateResetConfirm
+	stateSetup
+	statePrefixPick
+	statePrefixInput
+	stateVerify
+)
+
+type action int
+
+const (
+	actionInstall action = iota
+	actionUpgrade
+	actionUninstall
+	actionCleanReinstall
+	actionDeps
+	actionInstallExisting
+	actionRecompileVerbose
+	actionExportScript
+	actionViewLog
+	actionViewCMakeCache
+	actionVerify
+	actionHistory
+	actionBugReport
+	actionPresets
+	actionSettings
+	actionStepList
+	actionCleanLogs
+	actionReset
+	actionExit
 )
 
+type menuItem struct {
+	label  string
+	action action
+}
+
+var mainMenu = []menuItem{
+	{"Install TIC-80 Pro", actionInstall},
+	{"Upgrade (Rebuild)", actionUpgrade},
+	{"Uninstall", actionUninstall},
+	{"Clean Reinstall", actionCleanReinstall},
+	{"Install Dependencies Only", actionDeps},
+	{"Install Existing Build", actionInstallExisting},
+	{"Verify Installation", actionVerify},
+	{"Export Script", actionExportScript},
+	{"Step List", actionStepList},
+	{"View Last Log", actionViewLog},
+	{"View CMake Cache", actionViewCMakeCache},
+	{"Recent Builds", actionHistory},
+	{"Presets", actionPresets},
+	{"Settings", actionSettings},
+	{"Create Bug Report", actionBugReport},
+	{"Clean Logs", actionCleanLogs},
+	{"Reset Everything", actionReset},
+	{"Exit", actionExit},
+}
+
+// exportMenu picks which operation Export Script writes out, or Step List
+// shows.
+var exportMenu = []menuItem{
+	{"Install TIC-80 Pro", actionInstall},
+	{"Upgrade (Rebuild)", actionUpgrade},
+	{"Uninstall", actionUninstall},
+	{"Clean Reinstall", actionCleanReinstall},
+	{"Install Dependencies Only", actionDeps},
+	{"Install Existing Build", actionInstallExisting},
+}
+
+// buildsBinary reports whether a leaves TIC-80 installed under the prefix.
+func buildsBinary(a action) bool {
+	return a == actionInstall || a == actionUpgrade || a == actionCleanReinstall || a == actionInstallExisting
+}
+
+// model is only read and changed by Update and View, on Bubble Tea's one
+// goroutine. Steps run on goroutines of their own and never touch it: they
+// send their output, PID and result over stream, and the only state they
+// share, a step's error tail in runStepStreamed, has its own lock.
 type model struct {
 	width       int
 	height      int
 	cursor      int
-	choices     []string
+	choices     []menuItem
 	state       state
 	spinner     spinner.Model
 	
 	steps       []installStep
-	currentStep int
+	pending     action
+	checking    bool // preflight in progress
+	runStart    time.Time
+	runEnd      time.Time
+	currentStep int             // last step started, or the one that failed
+	started     []bool          // per step
+	finished    []bool          // per step, including aborted ones
+	running     map[int]int     // step -> process group, 0 until it has started
+	skipping    map[int]bool    // running steps aborted with S
+	aborted     []int           // steps skipped with S this run
+	warned      []int           // non-fatal steps that failed this run
+	warnLine    int             // line of the last warning, counting every line pushed
+	errLine     int             // line of the first error line, -1 for none
+	outputs     []*stepOutput   // per step, head and tail for the report
+	stepStart   []time.Time     // per step
+	durations   []time.Duration // per step, set when it succeeds
+	medCompile  time.Duration   // median of past compiles, 0 for no ETA
 	logMsg      string
 	err         error
 
 	// Terminal
 	viewport    viewport.Model
 	showTerm    bool
-	termContent string
+	termLines   scrollback // rendered, one per line
+	progress    string
+
+	// Set when a git step was throttled by GitHub.
+	rateLimited bool
+	rateReset   time.Time
+
+	pathWarning string
+	partial     []string // files missing from a half-finished install
+	installed   string   // version of the tic80 under the prefix
+	repoSize    int64    // TIC-80's size in KB from the GitHub API, 0 if unknown
+	sizeAsked   bool     // fetched, or fetching, repoSize
+
+	// View Last Log / Recent Builds
+	history     []historyEntry
+	histCursor  int
+	setCursor   int
+	stepCursor  int
+	pickFor     action // Export Script or Step List, while picking an operation
+	copyStatus  string
+	osc52       string   // OSC 52 sequence for View to send, see copyToClipboard
+	resetStage  int      // confirmations given on the reset screen, 2 once deleting
+	resetList   []string // what the reset screen will delete
+	setupCursor int
+	prefixRow   int
+	prefixBack  state // settings or setup, where the picker returns to
+	prefixInput string
+	prefixNotes []string // per picker row, from prefixNote
+	verified    bool     // verifyMsg has arrived
+	checks      []fileCheck
+	verifyErr   error
+	prefixErr   string
+	logPath     string
+	logBack     state
+	logView     string
+	logPos      int64
+	logIno      uint64
+	logFollow   bool
+	followGen   int
+	logFocus    bool // Tab gave the log pane the navigation keys
+	attachState string
+
+	opts     options
+	baseOpts options      // as started, so preset picks don't stack
+	edited   map[int]bool // rows of settings changed, kept over preset picks
+	stream   chan tea.Msg
+	logFile  *logWriter
 }
 
-func initialModel() model {
+func initialModel(opts options) model {
 	s := spinner.New()
 	s.Spinner = spinner.MiniDot
 	s.Style = lipgloss.NewStyle().Foreground(ColorRed).Background(ColorVoid)
 
 	vp := viewport.New(0, 0)
 	vp.Style = styleTermBox
+	// Compact mode can start logging before the first WindowSizeMsg.
+	vp.Width, vp.Height = clampViewport(vp, 0, 0)
 
 	return model{
-		choices:  []string{"Install TIC-80 Pro", "Upgrade (Rebuild)", "Uninstall", "Exit"},
-		spinner:  s,
-		state:    stateMenu,
-		logMsg:   "type help for help",
-		viewport: vp,
-		showTerm: false,
+		choices:   mainMenu,
+		spinner:   s,
+		state:     stateMenu,
+		logMsg:    "type help for help",
+		viewport:  vp,
+		showTerm:  false,
+		termLines: scrollback{max: opts.scrollback},
+		opts:      opts,
+		baseOpts:  opts,
+		edited:    map[int]bool{},
+		stream:    make(chan tea.Msg),
 	}
 }
 
+// startRunMsg begins m.pending straight away, for modes without a menu.
+type startRunMsg struct{}
+
 func (m model) Init() tea.Cmd {
-	return m.spinner.Tick
+	if m.opts.compact {
+		return func() tea.Msg { return startRunMsg{} }
+	}
+	if m.opts.attach != 0 {
+		return readLogChunk(m.logPath, 0, 0)
+	}
+	return tea.Batch(m.spinner.Tick, checkPartialInstall(m.opts), probeVersion(m.opts))
+}
+
+type stepLineMsg struct {
+	step   int
+	text   string
+	stderr bool
+}
+
+// tagged strips color codes, which styleTermText would mangle, and in
+// separate-streams mode marks where the line came from.
+func (msg stepLineMsg) tagged(opts options) string {
+	line := ansi.Strip(msg.text)
+	if opts.streams != "separate" {
+		return line
+	}
+	if msg.stderr {
+		return "[err] " + line
+	}
+	return "[out] " + line
 }
 
 type stepLogAndFinishMsg struct {
-	output string
-	err    error
+	step int
+	err  error
+}
+
+func formatLogLine(line string, opts options) string {
+	if opts.timestamps {
+		return "[" + formatClock(time.Now(), opts.timeFormat) + "] " + line
+	}
+	return line
+}
+
+// appendLog writes one line of output to the viewport and the log file.
+func (m *model) appendLog(line string) {
+	m.appendStyled(line, styleTermText)
+}
+
+func (m *model) appendStyled(line string, style lipgloss.Style) {
+	m.appendLine(line, style, classifyLine(ansi.Strip(line)))
+}
+
+// appendLine is appendStyled for step output, classified by the caller
+// before the line got its step and stream tags.
+func (m *model) appendLine(line string, style lipgloss.Style, class lineClass) {
+	line = formatLogLine(line, m.opts)
+	m.logFile.println(line, class)
+	// Only follow new output if the pane wasn't scrolled back.
+	follow := m.viewport.AtBottom()
+	// The viewport measures display width, but gives a tab none.
+	m.termLines.push(style.Render(strings.ReplaceAll(line, "\t", "    ")))
+	m.refreshTerm()
+	if follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *model) refreshTerm() {
+	lines := m.termLines.ordered()
+	if n := m.termLines.dropped; n > 0 {
+		note := styleLog.Render(fmt.Sprintf("... %d earlier lines not shown, see %s", n, LOG_FILE))
+		lines = append([]string{note}, lines...)
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// startStep launches step i; the caller makes sure the stream is read.
+func (m *model) startStep(i int) tea.Cmd {
+	step := m.steps[i]
+	m.currentStep = i
+	m.started[i] = true
+	m.stepStart[i] = time.Now()
+	m.running[i] = 0
+	m.progress = ""
+	m.appendLog(">>> " + step.desc)
+	return runStepStreamed(i, step, m.opts.streams == "separate", m.stream)
+}
+
+// startRun resets per-run state and starts preflight for m.pending; the
+// first step follows once it passes.
+func (m *model) startRun() tea.Cmd {
+	if len(m.steps) == 0 {
+		m.state = stateDone
+		m.err = noStepsError(m.pending)
+		m.runStart, m.runEnd = time.Now(), time.Now()
+		if m.opts.compact {
+			return tea.Quit
+		}
+		return nil
+	}
+	if err := lockInstance(); err != nil {
+		m.state = stateDone
+		m.err = err
+		return nil
+	}
+	m.state = stateRunning
+	m.currentStep = 0
+	m.started = make([]bool, len(m.steps))
+	m.outputs = newStepOutputs(len(m.steps), m.opts)
+	m.stepStart = make([]time.Time, len(m.steps))
+	m.durations = make([]time.Duration, len(m.steps))
+	m.medCompile = typicalCompile(loadHistory(), incrementalCompile(m.opts))
+	m.finished = make([]bool, len(m.steps))
+	m.running = map[int]int{}
+	m.skipping = map[int]bool{}
+	m.aborted = nil
+	m.warned = nil
+	m.errLine = -1
+	m.err = nil
+	m.rateLimited = false
+	m.pathWarning = ""
+	m.termLines.reset()
+	m.checking = true
+	m.runStart = time.Now()
+	m.logFile = createLog(m.opts.logFormat)
+	for _, line := range logHeader(m.pending, m.opts) {
+		m.appendStyled(line, styleInfo)
+	}
+	return tea.Batch(m.spinner.Tick, runPreflight(m.pending, m.opts))
+}
+
+// finishRun moves to the done screen. Compact mode has no done screen, so it
+// quits and leaves the final status line behind.
+func (m *model) finishRun(err error) tea.Cmd {
+	m.state = stateDone
+	m.err = err
+	m.runEnd = time.Now()
+	m.closeLog()
+	unlockInstance()
+	recordHistory(m.pending, m.opts, m.runStart, m.runEnd, compileTime(m.steps, m.durations), err)
+	failed := len(m.steps)
+	if err != nil {
+		failed = m.currentStep
+	}
+	marks := map[int]string{}
+	for _, i := range m.aborted {
+		marks[i] = "aborted"
+	}
+	for _, i := range m.warned {
+		marks[i] = "warning"
+	}
+	writeReport(m.pending, m.opts, m.steps, m.outputs, failed, marks, m.runStart, m.runEnd, err)
+	if m.opts.compact {
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *model) closeLog() {
+	m.logFile.Close()
+	m.logFile = nil
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -127,31 +561,331 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case tea.WindowSizeMsg:
+		// Multiplexers like tmux can report 0x0 while attaching.
+		if msg.Width == 0 && msg.Height == 0 {
+			return m, nil
+		}
 		m.width = msg.Width
 		m.height = msg.Height
-		m.viewport.Width = msg.Width - 4
-		m.viewport.Height = msg.Height / 3
+		m.layoutViewport()
 
 	case tea.KeyMsg:
+		if m.state == statePrefixInput {
+			return m.updatePrefixInput(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
+			if m.state == stateRunning {
+				for _, pid := range m.running {
+					killStep(pid)
+				}
+			}
 			return m, tea.Quit
-		case "tab", " ": // Spacebar or Tab toggles terminal
+		case "r":
+			if m.state == stateMenu && len(m.partial) > 0 {
+				m.pending = repairAction()
+				m.steps = getSteps(m.pending, m.opts)
+				m.state = stateSummary
+				m.partial = nil
+				return m, m.repoSizeCmd()
+			}
+			return m, nil
+		case "v", "V":
+			if m.canRecompileVerbose() {
+				m.pending = actionRecompileVerbose
+				m.steps = getSteps(m.pending, m.opts)
+				return m, m.startRun()
+			}
+			return m, nil
+		case "s":
+			if m.state != stateRunning || m.checking {
+				return m, nil
+			}
+			// With steps running side by side, skip the first one that may be.
+			var blocked []string
+			for _, i := range m.runningSteps() {
+				step := m.steps[i]
+				if m.running[i] == 0 || m.skipping[i] {
+					continue
+				}
+				if !skippable(step) {
+					blocked = append(blocked, step.desc)
+					continue
+				}
+				m.skipping[i] = true
+				m.appendStyled("!!! Aborting "+step.desc, styleError)
+				killStep(m.running[i])
+				return m, nil
+			}
+			for _, desc := range blocked {
+				m.appendStyled("--- "+desc+" can't be skipped, later steps need it", styleError)
+			}
+			return m, nil
+		case "y":
+			if m.state != stateResetConfirm || len(m.resetList) == 0 || m.resetStage > 1 {
+				return m, nil
+			}
+			if m.resetStage == 0 {
+				m.resetStage++
+				return m, nil
+			}
+			// Not while a headless build is using the tree.
+			if err := lockInstance(); err != nil {
+				m.state = stateDone
+				m.err = err
+				return m, nil
+			}
+			m.resetStage++
+			return m, resetEverything(m.resetList)
+		case "w":
+			if m.state == stateRunning || m.state == stateDone {
+				m.jumpToWarning()
+			}
+			return m, nil
+		// Pager keys, for the log when it has the focus. Scrolling back
+		// stops View Last Log from following.
+		case "g", "home", "G", "end", "pgup", "pgdown":
+			if !m.logFocused() {
+				return m, nil
+			}
+			switch msg.String() {
+			case "g", "home":
+				m.viewport.GotoTop()
+				m.logFollow = false
+			case "G", "end":
+				m.viewport.GotoBottom()
+			case "pgup":
+				m.viewport.PageUp()
+				m.logFollow = false
+			case "pgdown":
+				m.viewport.PageDown()
+			}
+			return m, nil
+		case " ": // Spacebar toggles terminal
 			m.showTerm = !m.showTerm
+			m.logFocus = m.logFocus && m.showTerm
+			return m, nil
+		case "tab": // Tab moves the keys between the screen and the terminal
+			if !m.showTerm {
+				m.showTerm, m.logFocus = true, true
+			} else {
+				m.logFocus = !m.logFocus
+			}
+			return m, nil
+		case "t":
+			m.opts.timestamps = !m.opts.timestamps
 			return m, nil
+		case "f":
+			if m.state == stateLogView {
+				m.logFollow = !m.logFollow
+				if m.logFollow {
+					m.followGen++
+					return m, readLogChunk(m.logPath, m.logPos, m.logIno)
+				}
+				return m, nil
+			}
 		case "up", "k":
-			if m.state == stateMenu && m.cursor > 0 { m.cursor-- }
+			if m.logFocused() {
+				break
+			}
+			if m.inMenu() && m.cursor > 0 { m.cursor-- }
+			if m.state == stateHistory && m.histCursor > 0 {
+				m.histCursor--
+			}
+			if m.state == stateSettings && m.setCursor > 0 {
+				m.setCursor--
+			}
+			if m.state == stateStepList && m.stepCursor > 0 {
+				m.stepCursor--
+			}
+			if m.state == stateSetup && m.setupCursor > 0 {
+				m.setupCursor--
+			}
+			if m.state == statePrefixPick && m.prefixRow > 0 {
+				m.prefixRow--
+				m.prefixErr = ""
+			}
 		case "down", "j":
-			if m.state == stateMenu && m.cursor < len(m.choices)-1 { m.cursor++ }
+			if m.logFocused() {
+				break
+			}
+			if m.inMenu() && m.cursor < len(m.choices)-1 { m.cursor++ }
+			if m.state == stateHistory && m.histCursor < len(m.history)-1 {
+				m.histCursor++
+			}
+			if m.state == stateSettings && m.setCursor < len(settings)-1 {
+				m.setCursor++
+			}
+			if m.state == stateStepList && m.stepCursor < len(m.steps)-1 {
+				m.stepCursor++
+			}
+			if m.state == stateSetup && m.setupCursor < len(setupSettings)+len(setupActions)-1 {
+				m.setupCursor++
+			}
+			if m.state == statePrefixPick && m.prefixRow < len(prefixChoices(m.opts)) {
+				m.prefixRow++
+				m.prefixErr = ""
+			}
+		case "esc":
+			if m.state == stateExportPick || m.state == statePresetPick {
+				m.state = stateMenu
+				m.choices = mainMenu
+				m.cursor = 0
+			} else if m.state == stateSummary {
+				m.state = stateMenu
+			} else if m.state == stateHistory || m.state == stateSettings || m.state == stateResetConfirm || m.state == stateVerify {
+				m.state = stateMenu
+			} else if m.state == stateSetup {
+				// Skipped: nothing is saved, so setup comes back next launch.
+				m.opts = m.baseOpts
+				m.state = stateMenu
+			} else if m.state == stateStepList {
+				m.state = stateMenu
+				m.choices = mainMenu
+				m.cursor = 0
+			} else if m.state == statePrefixPick {
+				m.state = m.prefixBack
+			} else if m.state == stateLogView {
+				m.state = m.logBack
+				m.logFollow = false
+				m.layoutViewport()
+				m.refreshTerm()
+			}
 		case "enter":
-			if m.state == stateMenu {
-				if m.cursor == 3 { return m, tea.Quit }
-				m.state = stateRunning
-				m.currentStep = 0
-				m.err = nil
-				m.termContent = ""
-				m.steps = getSteps(m.cursor)
-				return m, tea.Batch(m.spinner.Tick, runStepStreamed(m.steps[0]))
+			if m.state == stateExportPick && m.pickFor == actionStepList {
+				m.pending = m.choices[m.cursor].action
+				m.steps = getSteps(m.pending, m.opts)
+				m.state = stateStepList
+				m.stepCursor = 0
+				m.copyStatus, m.osc52 = "", ""
+				return m, nil
+			} else if m.state == stateStepList {
+				if len(m.steps) == 0 {
+					return m, nil
+				}
+				return m, copyToClipboard(m.steps[m.stepCursor].cmd)
+			} else if m.state == stateExportPick {
+				a := m.choices[m.cursor].action
+				path := fmt.Sprintf("tic80-%s.sh", operationNames[a])
+				return m, exportScript(path, a, m.opts)
+			} else if m.state == stateSettings && settings[m.setCursor].open == statePrefixPick {
+				m.openPrefixPick(stateSettings)
+				return m, nil
+			} else if m.state == stateSettings {
+				settings[m.setCursor].next(&m.opts)
+				m.edited[m.setCursor] = true
+				return m, nil
+			} else if m.state == stateSetup && m.setupCursor < len(setupSettings) && setupSettings[m.setupCursor].open == statePrefixPick {
+				m.openPrefixPick(stateSetup)
+				return m, nil
+			} else if m.state == stateSetup && m.setupCursor < len(setupSettings) {
+				setupSettings[m.setupCursor].next(&m.opts)
+				return m, nil
+			} else if m.state == statePrefixPick {
+				if choices := prefixChoices(m.opts); m.prefixRow < len(choices) {
+					m.pickPrefix(choices[m.prefixRow])
+				} else {
+					m.state = statePrefixInput
+					m.prefixInput = ""
+					m.prefixErr = ""
+				}
+				return m, nil
+			} else if m.state == stateSetup {
+				if err := writeSetupConfig(m.opts); err != nil {
+					m.state = stateDone
+					m.err = err
+					return m, nil
+				}
+				m.baseOpts.prefix, m.baseOpts.jobs = m.opts.prefix, m.opts.jobs
+				opts, err := withPreset(m.baseOpts, m.opts.preset)
+				if err != nil {
+					m.state = stateDone
+					m.err = err
+					return m, nil
+				}
+				m.keepEdits(&opts)
+				m.opts = opts
+				if m.setupCursor == len(setupSettings) {
+					m.pending = actionDeps
+					m.steps = getSteps(m.pending, m.opts)
+					m.state = stateSummary
+				} else {
+					m.state = stateMenu
+				}
+				return m, nil
+			} else if m.state == statePresetPick {
+				opts, err := withPreset(m.baseOpts, m.choices[m.cursor].label)
+				if err != nil {
+					m.state = stateDone
+					m.err = err
+					return m, nil
+				}
+				m.keepEdits(&opts)
+				m.opts = opts
+				m.termLines.max = opts.scrollback
+				m.state = stateMenu
+				m.choices = mainMenu
+				m.cursor = 0
+				return m, nil
+			} else if m.state == stateMenu {
+				switch m.choices[m.cursor].action {
+				case actionExit:
+					return m, tea.Quit
+				case actionExportScript, actionStepList:
+					m.pickFor = m.choices[m.cursor].action
+					m.state = stateExportPick
+					m.choices = exportMenu
+					m.cursor = 0
+					return m, nil
+				case actionViewLog:
+					return m, m.openLogView(LOG_FILE, stateMenu)
+				case actionViewCMakeCache:
+					return m, m.openLogView(CMAKE_CACHE_FILE, stateMenu)
+				case actionCleanLogs:
+					return m, cleanLogs(m.opts)
+				case actionVerify:
+					m.state = stateVerify
+					m.verified = false
+					return m, runVerify(m.opts)
+				case actionHistory:
+					m.state = stateHistory
+					m.history = loadHistory()
+					m.histCursor = 0
+					return m, nil
+				case actionReset:
+					m.state = stateResetConfirm
+					m.resetStage = 0
+					m.resetList = existingResetTargets(m.opts)
+					return m, nil
+				case actionSettings:
+					m.state = stateSettings
+					m.setCursor = 0
+					return m, nil
+				case actionPresets:
+					m.state = statePresetPick
+					m.choices = nil
+					for _, name := range presetNames(m.opts.presets) {
+						m.choices = append(m.choices, menuItem{name, actionPresets})
+					}
+					m.cursor = 0
+					return m, nil
+				case actionBugReport:
+					return m, createBugReport(m.opts)
+				}
+				m.pending = m.choices[m.cursor].action
+				m.steps = getSteps(m.pending, m.opts)
+				m.state = stateSummary
+				return m, m.repoSizeCmd()
+			} else if m.state == stateSummary && m.opts.dryRun {
+				m.state = stateDone
+				m.logMsg = "Dry run: nothing was executed."
+				return m, nil
+			} else if m.state == stateSummary {
+				return m, m.startRun()
+			} else if m.state == stateHistory && len(m.history) > 0 {
+				if path := m.history[m.histCursor].Log; path != "" {
+					return m, m.openLogView(path, stateHistory)
+				}
 			} else if m.state == stateDone {
 				return m, tea.Quit
 			}
@@ -163,29 +897,208 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
-	case stepLogAndFinishMsg:
-		// Add output to viewport
-		cmdName := m.steps[m.currentStep].desc
-		m.termContent += fmt.Sprintf(">>> %s\n%s\n", cmdName, msg.output)
-		m.viewport.SetContent(styleTermText.Render(m.termContent))
-		m.viewport.GotoBottom()
+	case stepLineMsg:
+		style := styleTermText
+		if msg.stderr && m.opts.streams == "separate" {
+			style = styleTermErr
+		}
+		line := msg.tagged(m.opts)
+		m.outputs[msg.step].add(line)
+		// Tell interleaved output apart while steps run side by side.
+		if len(m.running) > 1 {
+			line = "[" + strings.TrimSuffix(m.steps[msg.step].desc, "...") + "] " + line
+		}
+		class := classifyLine(ansi.Strip(msg.text))
+		m.appendLine(line, style, class)
+		if m.errLine < 0 && class == lineError {
+			// Shown as it happens; revealFailure scrolls back to it if the
+			// step gives up.
+			m.errLine = m.termLines.total() - 1
+			if m.opts.autoLog {
+				m.showTerm = true
+			}
+		}
+		if p := parseProgress(ansi.Strip(msg.text)); p != "" {
+			m.progress = p
+		}
+		return m, m.waitSteps()
+
+	case stepStartedMsg:
+		if _, ok := m.running[msg.step]; ok {
+			m.running[msg.step] = msg.pid
+		}
+		return m, m.waitSteps()
 
+	case stepProgressMsg:
+		if p := parseProgress(ansi.Strip(msg.text)); p != "" {
+			m.progress = p
+		}
+		return m, m.waitSteps()
+
+	case stepLogAndFinishMsg:
+		delete(m.running, msg.step)
+		if m.skipping[msg.step] {
+			delete(m.skipping, msg.step)
+			m.aborted = append(m.aborted, msg.step)
+			m.appendStyled("!!! "+m.steps[msg.step].desc+" aborted, continuing", styleError)
+			msg.err = nil
+		}
+		if m.state != stateRunning {
+			// Steps stopped after another one failed; just drain them.
+			return m, m.waitSteps()
+		}
+		if msg.err != nil && m.steps[msg.step].nonFatal {
+			m.warned = append(m.warned, msg.step)
+			m.warnLine = m.termLines.total()
+			m.appendStyled("WARNING: "+msg.err.Error()+", continuing", styleError)
+			msg.err = nil
+		}
 		if msg.err != nil {
-			m.state = stateDone
-			m.err = msg.err
+			m.currentStep = msg.step
+			for _, pid := range m.running {
+				killStep(pid)
+			}
+			m.revealFailure()
+			if cmd := m.finishRun(msg.err); cmd != nil {
+				return m, cmd
+			}
+			if isRateLimited(msg.err) {
+				m.rateLimited = true
+				return m, tea.Batch(fetchRateLimitReset(), m.waitSteps())
+			}
+			return m, m.waitSteps()
+		}
+		m.finished[msg.step] = true
+		m.durations[msg.step] = time.Since(m.stepStart[msg.step])
+		if m.finishedCount() >= len(m.steps) {
+			m.logMsg = fmt.Sprintf("Process Completed (%d steps).", len(m.steps))
+			if len(m.aborted) > 0 {
+				m.logMsg = fmt.Sprintf("Process Completed (%d steps, %d aborted).", len(m.steps), len(m.aborted))
+			}
+			if cmd := m.finishRun(nil); cmd != nil {
+				return m, cmd
+			}
+			if buildsBinary(m.pending) && !m.opts.noInstall {
+				return m, checkPath(m.opts)
+			}
 			return m, nil
 		}
-		m.currentStep++
-		if m.currentStep >= len(m.steps) {
-			m.state = stateDone
-			m.logMsg = "Process Completed."
+		return m, tea.Batch(append(m.launchReady(), m.waitSteps())...)
+
+	case startRunMsg:
+		return m, m.startRun()
+
+	case preflightMsg:
+		m.checking = false
+		for _, w := range msg.warnings {
+			m.appendLog("WARNING: " + w)
+		}
+		if msg.err != nil {
+			m.appendLog("Preflight failed: " + msg.err.Error())
+			return m, m.finishRun(msg.err)
+		}
+		return m, tea.Batch(append(m.launchReady(), m.waitSteps())...)
+
+	case logChunkMsg:
+		if m.state != stateLogView {
 			return m, nil
 		}
-		return m, runStepStreamed(m.steps[m.currentStep])
+		if msg.err != nil {
+			m.logView = "Could not read " + m.logPath + ": " + msg.err.Error()
+		} else {
+			if msg.reset {
+				m.logView = ""
+			}
+			m.logView += msg.data
+			m.logPos, m.logIno = msg.pos, msg.ino
+		}
+		m.viewport.SetContent(styleTermText.Render(logText(m.logView)))
+		if m.opts.attach != 0 {
+			m.attachState = attachStatus(m.opts.attach)
+		}
+		if m.logFollow {
+			m.viewport.GotoBottom()
+			return m, logFollowTick(m.followGen)
+		}
+		return m, nil
+
+	case logFollowTickMsg:
+		// Ticks from an earlier follow session are dropped.
+		if m.state == stateLogView && m.logFollow && msg.gen == m.followGen {
+			return m, readLogChunk(m.logPath, m.logPos, m.logIno)
+		}
+		return m, nil
+
+	case partialInstallMsg:
+		m.partial = msg.missing
+
+	case verifyMsg:
+		m.verified, m.checks, m.verifyErr = true, msg.checks, msg.err
+
+	case resetDoneMsg:
+		unlockInstance()
+		m.state = stateDone
+		m.err = msg.err
+		if msg.err == nil {
+			m.logMsg = "Everything the tool created has been deleted."
+		}
+
+	case scriptWrittenMsg:
+		m.state = stateDone
+		m.err = msg.err
+		if msg.err == nil {
+			m.logMsg = "Script written to " + msg.path
+		}
+
+	case bugReportMsg:
+		m.state = stateDone
+		m.err = msg.err
+		if msg.err == nil {
+			m.logMsg = "Bug report written to " + msg.path
+		}
+
+	case logsCleanedMsg:
+		m.state = stateDone
+		m.err = msg.err
+		m.logMsg = msg.rotation.String()
+
+	case installedVersionMsg:
+		m.installed = msg.version
+
+	case clipboardMsg:
+		if msg.err != nil {
+			m.copyStatus = "Copy failed: " + msg.err.Error()
+		} else {
+			m.copyStatus = fmt.Sprintf("Copied step %d to the clipboard via %s", m.stepCursor+1, msg.via)
+		}
+		m.osc52 = ""
+		if msg.osc52 != "" {
+			m.osc52 = ansi.SetSystemClipboard(msg.osc52)
+		}
+
+	case pathCheckMsg:
+		m.pathWarning = msg.warning
+
+	case repoSizeMsg:
+		m.repoSize = msg.kb
+
+	case rateLimitMsg:
+		m.rateReset = msg.reset
+		if !m.rateReset.IsZero() {
+			return m, rateLimitTick()
+		}
+
+	case rateLimitTickMsg:
+		if time.Now().Before(m.rateReset) {
+			return m, rateLimitTick()
+		}
 	}
 
-	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	// Keys only scroll the log when it has the focus.
+	if _, ok := msg.(tea.KeyMsg); !ok || m.logFocused() {
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	}
 
 	return m, tea.Batch(cmds...)
 }
@@ -193,97 +1106,1009 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m model) View() string {
 	var s strings.Builder
 
+	if m.opts.compact {
+		return m.compactView()
+	}
+
+	// Width/height are zero until the first WindowSizeMsg arrives.
+	if m.width > 0 && (m.width < MIN_WIDTH || m.height < MIN_HEIGHT) {
+		msg := fmt.Sprintf("Terminal too small — resize to at least %dx%d", MIN_WIDTH, MIN_HEIGHT)
+		return styleApp.Width(m.width).Height(m.height).Render(styleError.Render(msg))
+	}
+
 	title := renderRainbow("TIC-80 PRO MANAGER")
 	version := lipgloss.NewStyle().Foreground(ColorGrey).Background(ColorVoid).Render(" version 1.2.3019 (fedora)")
 	s.WriteString("\n " + title + "\n " + version + "\n\n")
 
-	if m.state == stateMenu {
+	if m.inMenu() {
 		for i, choice := range m.choices {
 			if m.cursor == i {
 				cursor := lipgloss.NewStyle().Foreground(ColorRed).Background(ColorVoid).Render(">█ ")
-				s.WriteString(" " + cursor + styleSelected.Render(choice) + "\n")
+				s.WriteString(" " + cursor + styleSelected.Render(choice.label) + "\n")
 			} else {
-				s.WriteString("    " + styleNormal.Render(choice) + "\n")
+				s.WriteString("    " + styleNormal.Render(choice.label) + "\n")
 			}
 		}
 		s.WriteString("\n " + styleLog.Render("Use arrow keys to select..."))
-		s.WriteString("\n " + styleLog.Render("Press SPACE to toggle Logs"))
+		if m.state == stateExportPick && m.pickFor == actionStepList {
+			s.WriteString("\n " + styleLog.Render("Pick the operation to list, Esc to go back"))
+		} else if m.state == stateExportPick {
+			s.WriteString("\n " + styleLog.Render("Pick the operation to export, Esc to go back"))
+		}
+		if m.state == statePresetPick {
+			p, _ := lookupPreset(m.choices[m.cursor].label, m.opts.presets)
+			s.WriteString("\n " + styleLog.Render(p.desc))
+			s.WriteString("\n " + styleLog.Render("Enter applies the preset, Esc to go back"))
+		} else if m.opts.preset != "" {
+			s.WriteString("\n " + styleLog.Render("Preset: "+m.opts.preset))
+		}
+		if m.state == stateMenu && m.installed != "" {
+			s.WriteString("\n " + styleLog.Render("Installed: "+m.installed+" in "+m.opts.prefix))
+		}
+		if m.state == stateMenu && len(m.partial) > 0 {
+			s.WriteString("\n\n " + styleError.Render(fmt.Sprintf("Partial install detected, %d files missing (e.g. %s).", len(m.partial), m.partial[0])))
+			s.WriteString("\n " + styleLog.Render("Press R to repair it."))
+		}
+		s.WriteString("\n " + styleLog.Render("Press SPACE to toggle Logs, TAB to move between menu and log"))
+
+	} else if m.state == stateSummary {
+		s.WriteString(renderSummary(m.pending, m.steps, m.opts, m.repoSize))
+
+	} else if m.state == stateSettings {
+		s.WriteString(renderSettings(m.opts, m.setCursor, m.width))
+
+	} else if m.state == stateSetup {
+		s.WriteString(renderSetup(m.opts, m.setupCursor))
+
+	} else if m.state == statePrefixPick || m.state == statePrefixInput {
+		s.WriteString(renderPrefixPick(m.opts, m.prefixNotes, m.prefixRow, m.prefixInput, m.state == statePrefixInput, m.prefixErr))
+
+	} else if m.state == stateResetConfirm {
+		s.WriteString(renderResetConfirm(m.resetList, m.resetStage))
+
+	} else if m.state == stateVerify {
+		s.WriteString(renderVerify(m.checks, m.verifyErr, m.verified, max(5, m.height-14)))
+
+	} else if m.state == stateStepList {
+		s.WriteString(renderStepList(m.pending, m.steps, m.stepCursor, m.width, m.copyStatus) + m.osc52)
+
+	} else if m.state == stateHistory {
+		s.WriteString(renderHistory(m.history, m.histCursor, m.height-10, m.opts.timeFormat))
+
+	} else if m.state == stateLogView {
+		follow := "off"
+		if m.logFollow {
+			follow = "on"
+		}
+		s.WriteString(" " + styleSelected.Render(m.logPath) + "\n")
+		if m.attachState != "" {
+			s.WriteString(" " + styleLog.Render(m.attachState) + "\n")
+		}
+		s.WriteString("\n")
+		s.WriteString(renderTermBox(m.viewport, ColorGrey) + "\n")
+		s.WriteString("\n " + styleLog.Render("F: follow ("+follow+")  g/G: start/end  PgUp/PgDn: page  Esc: back"))
+		return styleApp.Width(m.width).Height(m.height).Render(s.String())
 
 	} else if m.state == stateRunning {
-		currentDesc := m.steps[m.currentStep].desc
+		currentDesc := m.runningDesc()
+		if m.checking {
+			currentDesc = "Running preflight checks..."
+		}
 		row := fmt.Sprintf(" %s %s", m.spinner.View(), styleNormal.Render(currentDesc))
 		s.WriteString(row + "\n\n")
 		
-		progress := fmt.Sprintf(" Step %d of %d", m.currentStep+1, len(m.steps))
+		progress := fmt.Sprintf(" Step %d of %d", min(m.finishedCount()+1, len(m.steps)), len(m.steps))
+		if m.progress != "" {
+			progress += " · " + m.progress
+		}
+		if eta := m.etaStatus(); eta != "" {
+			progress += " · " + eta
+		}
+		progress += " · " + m.elapsed().String()
 		s.WriteString(styleLog.Render(progress))
-		s.WriteString("\n " + styleLog.Render("Press SPACE to toggle Logs"))
+		s.WriteString("\n " + styleLog.Render("Press SPACE to toggle Logs, S to skip this step"))
+		if status := m.warningStatus(); status != "" {
+			s.WriteString("\n " + styleError.Render(status))
+		}
 
 	} else if m.state == stateDone {
+		if status := m.warningStatus(); status != "" {
+			s.WriteString(" " + styleError.Render(status) + "\n")
+		}
+		if m.opts.label != "" && !m.runEnd.IsZero() {
+			s.WriteString(" " + styleLog.Render("Label: ") + styleSelected.Render(m.opts.label) + "\n")
+		}
 		if m.err != nil {
 			s.WriteString(" " + styleError.Render("FAILED"))
+			if vendor := failedVendorOf(m.err); vendor != "" {
+				s.WriteString("  " + styleSelected.Render("Build failed in "+vendor))
+			}
 			s.WriteString("\n " + styleLog.Render(m.err.Error()))
+			if m.rateLimited {
+				s.WriteString("\n " + styleError.Render(rateLimitNotice(m.rateReset)))
+			}
+			if m.canRecompileVerbose() {
+				s.WriteString("\n\n " + styleLog.Render("Press V to compile again where it stopped; "+VERBOSE_HINT+"."))
+			}
 		} else {
 			s.WriteString(" " + styleSuccess.Render("SUCCESS"))
 			s.WriteString("\n " + styleLog.Render(m.logMsg))
+			if m.opts.output != "" && buildsBinary(m.pending) {
+				s.WriteString("\n " + styleLog.Render("Binary copied to "+m.opts.output))
+			}
+			if len(m.warned) > 0 {
+				s.WriteString("\n\n " + styleError.Render("Non-fatal steps that failed:"))
+				for _, i := range m.warned {
+					s.WriteString("\n   " + styleLog.Render(m.steps[i].desc))
+				}
+			}
+			if m.pathWarning != "" {
+				s.WriteString("\n\n " + styleError.Render(m.pathWarning))
+			}
 		}
 		s.WriteString("\n\n " + styleLog.Render("Press Enter to Exit."))
 	}
 
 	if m.showTerm {
 		s.WriteString("\n\n")
-		s.WriteString(m.viewport.View())
+		s.WriteString(renderTermBox(m.viewport, m.termBorder()))
 	}
 
 	return styleApp.Width(m.width).Height(m.height).Render(s.String())
 }
 
-func getSteps(choice int) []installStep {
-	// We use /var/tmp to avoid RAM disk limits
-	buildDir := "/var/tmp/tic80-build"
-	
+// layoutViewport sizes the log pane: a third of the screen under the menu, or
+// most of it when viewing the last log.
+func (m *model) layoutViewport() {
+	height := m.height / 3
+	if m.state == stateLogView {
+		height = m.height - 8
+	}
+	m.viewport.Width, m.viewport.Height = clampViewport(m.viewport, m.width-4, height)
+}
+
+// clampViewport keeps at least one line and column inside the border;
+// anything smaller makes the viewport slice out of range when scrolling.
+func clampViewport(vp viewport.Model, width, height int) (int, int) {
+	minWidth := vp.Style.GetHorizontalFrameSize() + 1
+	minHeight := vp.Style.GetVerticalFrameSize() + 1
+	return max(width, minWidth), max(height, minHeight)
+}
+
+// termBorder colors the log pane by state, so it shows how the run is going
+// even when it is all you're looking at.
+func (m model) termBorder() lipgloss.CompleteColor {
+	switch {
+	case m.navigable() && m.logFocus:
+		return ColorWhite
+	case m.state == stateRunning:
+		return ColorYellow
+	case m.state == stateDone && m.err != nil:
+		return ColorRed
+	case m.state == stateDone:
+		return ColorGreen
+	}
+	return ColorGrey
+}
+
+// logFocused is whether the navigation keys go to the log instead of the
+// screen above it. The log has them by itself in View Last Log, and whenever
+// it is open under a screen with nothing to move through.
+func (m model) logFocused() bool {
+	if m.state == stateLogView {
+		return true
+	}
+	return m.showTerm && (m.logFocus || !m.navigable())
+}
+
+// navigable is whether the screen has a cursor the arrow keys move.
+func (m model) navigable() bool {
+	switch m.state {
+	case stateSettings, stateHistory, stateStepList, stateSetup, statePrefixPick:
+		return true
+	}
+	return m.inMenu()
+}
+
+func (m model) inMenu() bool {
+	return m.state == stateMenu || m.state == stateExportPick || m.state == statePresetPick
+}
+
+// cmakeArgs are the options passed to the configure step.
+func cmakeArgs(opts options) []string {
 	// FIX: Explicitly force the 'TIC80_PRO' definition into C/C++ flags.
 	// This ensures the compiler sees it even if CMake logic misses it.
-	cmakeFlags := "-DCMAKE_C_FLAGS=\"-DTIC80_PRO\" -DCMAKE_CXX_FLAGS=\"-DTIC80_PRO\" -DBUILD_PRO=On -DBUILD_WITH_ALL=On -DBUILD_SDL=On -DBUILD_SDLGPU=On -DBUILD_STATIC=On"
+	cflags := "-DTIC80_PRO"
+	if opts.reproducible {
+		// Keep the build directory out of __FILE__ and debug info.
+		cflags += " " + compilerFlagQuote("-ffile-prefix-map="+sourceDir(opts)+"=.")
+	}
+	sdlgpu := "On"
+	if opts.renderer == "sdl" {
+		sdlgpu = "Off"
+	}
+	args := []string{
+		shellQuote("-DCMAKE_C_FLAGS=" + cflags),
+		shellQuote("-DCMAKE_CXX_FLAGS=" + cflags),
+		"-DBUILD_PRO=On", "-DBUILD_WITH_ALL=On", "-DBUILD_SDL=On", "-DBUILD_SDLGPU=" + sdlgpu, "-DBUILD_STATIC=On",
+		shellQuote("-DCMAKE_INSTALL_PREFIX=" + opts.prefix),
+	}
+	if opts.reproducible {
+		args = append(args, "-DCMAKE_BUILD_TYPE=Release")
+	}
+	if CCACHE_DIR != "" {
+		args = append(args, "-DCMAKE_C_COMPILER_LAUNCHER=ccache", "-DCMAKE_CXX_COMPILER_LAUNCHER=ccache")
+	}
+	if opts.vendorCache {
+		args = append(args, "-DPREFER_SYSTEM_LIBRARIES=On", shellQuote("-DCMAKE_PREFIX_PATH="+VENDOR_BUNDLE))
+	}
+	// Later definitions of the same variable win in cmake.
+	for _, f := range opts.cmakeFlags {
+		args = append(args, shellQuote(f))
+	}
+	return args
+}
+
+// compilerFlagQuote keeps one flag whole inside CMAKE_C_FLAGS, which cmake
+// writes as it is into the build rules: make (or ninja) turns $$ into $, then
+// the shell that runs the compiler takes the double quotes and backslashes.
+func compilerFlagQuote(flag string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$$`)
+	return `"` + r.Replace(flag) + `"`
+}
+
+// CMAKE_ARG_PREFIXES are the cmake options accepted from --cmake-arg besides
+// -D definitions. -S and -B aren't, they would move the build.
+var CMAKE_ARG_PREFIXES = []string{"-U", "-G", "-T", "-A", "-W", "--log-level=", "--warn-", "--debug-", "--trace", "--fresh"}
+
+func validCMakeArg(arg string) bool {
+	if strings.HasPrefix(arg, "-D") && len(arg) > 2 {
+		return true
+	}
+	for _, prefix := range CMAKE_ARG_PREFIXES {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshDesktopStep makes the launcher entry and icon show up without a
+// re-login. Desktops cope without it, so a failure is only a warning.
+func refreshDesktopStep(opts options) installStep {
+	share := filepath.Join(opts.prefix, "share")
+	return installStep{
+		desc:     "Updating desktop database...",
+		cmd:      fmt.Sprintf("update-desktop-database -q %s && gtk-update-icon-cache -q -t -f %s", shellQuote(share+"/applications"), shellQuote(share+"/icons/hicolor")),
+		nonFatal: true,
+	}
+}
+
+// depsSteps install the toolchain and libraries the build needs.
+func depsSteps(opts options) []installStep {
+	return []installStep{
+		nonInteractive(installStep{desc: "Installing Group Tools...", cmd: opts.depsTools}),
+		nonInteractive(installStep{desc: "Installing Deps (GLU/Curl/X11)...", cmd: opts.depsPkgs}),
+	}
+}
+
+// isDepsStep goes by desc, since the commands come from presets and distro
+// definitions and may use any package manager.
+func isDepsStep(step installStep) bool {
+	for _, deps := range depsSteps(options{}) {
+		if step.desc == deps.desc {
+			return true
+		}
+	}
+	return false
+}
+
+// noStepsError is what running an operation without steps gives, rather than
+// a run that never ends or an index out of range.
+func noStepsError(a action) error {
+	name := operationNames[a]
+	if name == "" {
+		name = "this operation"
+	}
+	return fmt.Errorf("no steps defined for %s", name)
+}
+
+// compileEnv is the environment the configure and compile steps export.
+func compileEnv(opts options) string {
+	env := ""
+	if opts.reproducible {
+		env = fmt.Sprintf("export SOURCE_DATE_EPOCH=$(cat %s) && ", shellQuote(EPOCH_FILE))
+	}
+	if CCACHE_DIR != "" {
+		env += fmt.Sprintf("export CCACHE_DIR=%s && ", shellQuote(CCACHE_DIR))
+	}
+	return env
+}
+
+// compileStep runs make in the build tree obj, with args after the job count.
+func compileStep(desc, obj string, opts options, args string) installStep {
+	cmd := fmt.Sprintf("%scd %s && %s", compileEnv(opts), obj, wrapped("make -j"+jobsArg(opts)+args, opts))
+	step := asBuildUser(sandboxed(installStep{desc: desc, cmd: cmd}, opts), opts)
+	if opts.performance && cpuGovernor() != "" {
+		// Outside the sandbox, which can't write to /sys.
+		step.cmd = withPerformanceGovernor(step.cmd)
+	}
+	return step
+}
+
+func getSteps(choice action, opts options) []installStep {
+	return renderLabels(choice, opts, withHooks(choice, opts, baseSteps(choice, opts)))
+}
+
+// baseSteps are the steps of choice before any hooks. Every path goes
+// through shellQuote: the XDG directories, --prefix and --source-dir can
+// hold spaces, quotes or $.
+func baseSteps(choice action, opts options) []installStep {
+	buildDir := shellQuote(BUILD_DIR)
+	cmakeFlags := strings.Join(cmakeArgs(opts), " ")
+	// Kept for the partial-install check and Verify Installation; not worth
+	// failing the install over.
+	saveManifest := saveChecksums("install -D -m 644 install_manifest.txt " + shellQuote(MANIFEST_FILE))
+	if cmd := chownInstalled(opts); cmd != "" {
+		saveManifest = cmd + " && " + saveManifest
+	}
 
 	switch choice {
-	case 0, 1: // Install
-		return []installStep{
-			{"Installing Group Tools...", DEPS_CMD},
-			{"Installing Deps (GLU/Curl/X11)...", DEPS_PKGS},
-			{"Cleaning previous builds...", fmt.Sprintf("rm -rf %s", buildDir)},
-			{"Creating build directory...", fmt.Sprintf("mkdir -p %s", buildDir)},
-			{"Cloning Repository...", fmt.Sprintf("git clone --recursive https://github.com/nesbox/TIC-80.git %s/TIC-80", buildDir)},
-			{"Patching SDL2...", fmt.Sprintf("cd %s/TIC-80/vendor/sdl2 && git fetch --tags && git checkout release-2.32.8", buildDir)},
-			{"Configuring CMake (Forcing Pro)...", fmt.Sprintf("mkdir -p %s/TIC-80/build && cd %s/TIC-80/build && cmake %s ..", buildDir, buildDir, cmakeFlags)},
-			{"Compiling...", fmt.Sprintf("cd %s/TIC-80/build && make -j$(nproc)", buildDir)},
-			{"Installing...", fmt.Sprintf("cd %s/TIC-80/build && make install", buildDir)},
-			{"Cleaning up...", fmt.Sprintf("rm -rf %s", buildDir)},
-		}
-	case 2: // Uninstall
+	case actionInstall, actionUpgrade:
+		src := sourceDir(opts)
+		branch := ""
+		if opts.ref != "" {
+			branch = "--branch " + shellQuote(opts.ref) + " "
+		}
+		recursive := "--recursive "
+		if opts.submoduleJobs > 0 {
+			// Fetched by a separate, parallel submodule update below.
+			recursive = ""
+		}
+		clone := cloneFromMirror(func(repo string) string {
+			return fmt.Sprintf("git clone %s--progress %s%s %s", recursive, branch, repo, shellQuote(SRC_DIR))
+		}, opts)
+		submodules := fmt.Sprintf("git -C %s submodule update --init --recursive --progress --jobs %d", shellQuote(SRC_DIR), opts.submoduleJobs)
+		steps := depsSteps(opts)
+		// The source doesn't need the deps, so fetch it while they install,
+		// unless git itself is still to come from them.
+		var fetchAfter []string
+		if _, err := exec.LookPath("git"); err == nil {
+			fetchAfter = []string{}
+		}
+		fetch := "HEAD"
+		if opts.ref != "" {
+			fetch = shellQuote(opts.ref)
+		}
+		if opts.sourceDir != "" {
+			// Built as it is; the tree belongs to whoever checked it out.
+		} else if opts.cache {
+			// Keep the tree from the last run and bring it up to date instead.
+			// A directory without .git is what's left of an interrupted clone.
+			clone := fmt.Sprintf("rm -rf %s && %s", shellQuote(SRC_DIR), clone)
+			update := fmt.Sprintf(
+				"if [ -d %s/.git ]; then git -C %s fetch --progress --tags origin %s && git -C %s reset --hard FETCH_HEAD && git -C %s submodule update --init --recursive --progress; else mkdir -p %s && %s; fi",
+				shellQuote(SRC_DIR), shellQuote(SRC_DIR), fetch, shellQuote(SRC_DIR), shellQuote(SRC_DIR), buildDir, clone)
+			if opts.submoduleJobs > 0 {
+				update = fmt.Sprintf(
+					"if [ -d %s/.git ]; then git -C %s fetch --progress --tags origin %s && git -C %s reset --hard FETCH_HEAD; else mkdir -p %s && %s; fi && %s",
+					shellQuote(SRC_DIR), shellQuote(SRC_DIR), fetch, shellQuote(SRC_DIR), buildDir, clone, submodules)
+			}
+			steps = append(steps, handedOver(asBuildUser(installStep{desc: "Updating Repository...", cmd: update, dependsOn: fetchAfter}, opts), opts, BUILD_DIR))
+		} else {
+			create := fmt.Sprintf("mkdir -p %s", buildDir)
+			if own := handOver(opts, BUILD_DIR); own != "" {
+				create = own
+			}
+			steps = append(steps, []installStep{
+				{desc: "Cleaning previous builds...", cmd: cleanBuildDir(), dependsOn: fetchAfter},
+				{desc: "Creating build directory...", cmd: create, dependsOn: []string{"Cleaning previous builds..."}},
+				asBuildUser(installStep{desc: "Cloning Repository...", cmd: resumableClone(clone, fetch, opts.submoduleJobs == 0), dependsOn: []string{"Creating build directory..."}}, opts),
+			}...)
+			if opts.submoduleJobs > 0 {
+				// The clone isn't complete until its submodules are.
+				submodules += " && rm -f " + shellQuote(cloneMarker())
+				steps = append(steps, asBuildUser(installStep{desc: "Fetching Submodules...", cmd: submodules, dependsOn: []string{"Cloning Repository..."}}, opts))
+			}
+		}
+		// A --source-dir build still keeps the epoch, stamps and ccache here.
+		var own []string
+		if opts.sourceDir != "" {
+			own = []string{BUILD_DIR}
+		}
+		if opts.verifySignature {
+			steps = append(steps, asBuildUser(verifySignatureStep(src, opts), opts))
+		}
+		if opts.patchSDL && opts.sourceDir == "" {
+			steps = append(steps, asBuildUser(installStep{desc: "Patching SDL2...", cmd: fmt.Sprintf("cd %s && git fetch --tags && git checkout %s", shellQuote(SRC_DIR+"/vendor/sdl2"), shellQuote(opts.sdlVersion))}, opts))
+		}
+		if opts.reproducible {
+			epoch := asBuildUser(installStep{desc: "Pinning SOURCE_DATE_EPOCH...", cmd: fmt.Sprintf("mkdir -p %s && %s log -1 --format=%%ct | tee %s", buildDir, gitIn(src), shellQuote(EPOCH_FILE))}, opts)
+			steps = append(steps, handedOver(epoch, opts, own...))
+		}
+		buildEnv := compileEnv(opts)
+		if opts.vendorCache {
+			steps = append(steps, vendorBundleStep(src, opts))
+		}
+		obj := shellQuote(src + "/build")
+		configure := sandboxed(installStep{desc: "Configuring CMake (Forcing Pro)...", cmd: fmt.Sprintf("%smkdir -p %s && cd %s && cmake %s ..", buildEnv, obj, obj, cmakeFlags)}, opts)
+		if opts.cache {
+			configure = cached(configure, "configure", src+"/build/CMakeCache.txt", configureInputs(opts))
+		}
+		configure = handedOver(asBuildUser(configure, opts), opts, own...)
+		compile := compileStep("Compiling...", obj, opts, "")
+		compile.label = "Compiling TIC-80 {{with .Ref}}{{.}} {{end}}with {{.Jobs}} jobs..."
+		// make is incremental already, so compile and install always run.
+		steps = append(steps, configure, recordCMakeCacheStep(src), proFlagsStep(src), compile)
+		if opts.output != "" {
+			steps = append(steps, exportBinaryStep(src, opts))
+		}
+		if opts.noInstall {
+			steps = append(steps, proBinaryStep(opts.output), launchStep(opts.output))
+		} else {
+			steps = append(steps, installStep{desc: "Installing...", cmd: fmt.Sprintf("cd %s && make install && %s", obj, saveManifest)}, proBinaryStep(binPath(opts.prefix)), launchStep(binPath(opts.prefix)))
+			if opts.installDemos {
+				steps = append(steps, installDemosStep(src))
+			}
+			steps = append(steps, refreshDesktopStep(opts))
+			if opts.registerMime {
+				steps = append(steps, registerMimeStep(opts))
+			}
+			if opts.installService {
+				steps = append(steps, serviceInstallSteps(opts)...)
+			}
+		}
+		if opts.cache || opts.keepBuild || opts.sourceDir != "" {
+			return steps
+		}
+		return append(steps, installStep{desc: "Cleaning up...", cmd: fmt.Sprintf("rm -rf %s", buildDir), nonFatal: true})
+	case actionRecompileVerbose:
+		// make picks up where the failed compile stopped, printing each
+		// command line in full.
+		obj := shellQuote(sourceDir(opts) + "/build")
 		return []installStep{
-			{"Removing Binary...", "rm -f /usr/local/bin/tic80"},
-			{"Removing Desktop...", "rm -f /usr/local/share/applications/tic80.desktop"},
-			{"Removing Icon...", "rm -f /usr/local/share/icons/hicolor/scalable/apps/tic80.svg"},
+			{desc: "Checking build tree...", cmd: fmt.Sprintf("test -f %s/Makefile || { echo 'No configured build found at '%s' to compile in.' >&2; exit 1; }", obj, obj)},
+			compileStep("Recompiling verbosely...", obj, opts, " VERBOSE=1"),
+		}
+	case actionInstallExisting:
+		src := sourceDir(opts)
+		built := shellQuote(src + "/build/bin/tic80")
+		obj := shellQuote(src + "/build")
+		steps := []installStep{
+			{desc: "Checking existing build...", cmd: fmt.Sprintf("test -x %s || { echo 'No build found at '%s', run an install with --keep-build first.' >&2; exit 1; }", built, built)},
+		}
+		if opts.output != "" {
+			steps = append(steps, exportBinaryStep(src, opts))
+		}
+		if opts.noInstall {
+			return append(steps, proBinaryStep(opts.output), launchStep(opts.output))
+		}
+		steps = append(steps,
+			// cmake --install takes the prefix as given, so no reconfigure.
+			installStep{desc: "Installing...", cmd: fmt.Sprintf("cmake --install %s --prefix %s && cd %s && %s", obj, shellQuote(opts.prefix), obj, saveManifest)},
+			proBinaryStep(binPath(opts.prefix)),
+			launchStep(binPath(opts.prefix)),
+			refreshDesktopStep(opts),
+		)
+		if opts.registerMime {
+			steps = append(steps, registerMimeStep(opts))
+		}
+		if opts.installDemos {
+			steps = append(steps, installDemosStep(src))
 		}
+		if opts.installService {
+			steps = append(steps, serviceInstallSteps(opts)...)
+		}
+		return steps
+	case actionCleanReinstall:
+		// The install's hooks are added around the whole operation, so the
+		// pre-install hook runs before the uninstall.
+		return append(withHooks(actionUninstall, opts, baseSteps(actionUninstall, opts)), baseSteps(actionInstall, opts)...)
+	case actionDeps:
+		return depsSteps(opts)
+	case actionUninstall:
+		return uninstallSteps(opts)
 	}
 	return nil
 }
 
-func runStepStreamed(step installStep) tea.Cmd {
+// runStepStreamed sends each line of the step's output to out as it is
+// produced, followed by a stepLogAndFinishMsg once the command exits. stdout
+// and stderr share one pipe unless separate is set, in which case lines are
+// marked with their source (and may interleave less faithfully).
+func runStepStreamed(index int, step installStep, separate bool, out chan<- tea.Msg) tea.Cmd {
 	return func() tea.Msg {
+		start := time.Now()
 		cmd := exec.Command("bash", "-c", step.cmd)
-		output, err := cmd.CombinedOutput()
-		return stepLogAndFinishMsg{output: string(output), err: err}
+		// Own process group, so cancelling reaches everything the step runs.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		outR, outW, err := os.Pipe()
+		if err != nil {
+			out <- stepLogAndFinishMsg{step: index, err: classifyStepError(step, start, nil, err)}
+			return nil
+		}
+		readers := []*os.File{outR}
+		writers := []*os.File{outW}
+		cmd.Stdout = outW
+		cmd.Stderr = outW
+		if separate {
+			errR, errW, err := os.Pipe()
+			if err != nil {
+				outR.Close()
+				outW.Close()
+				out <- stepLogAndFinishMsg{step: index, err: classifyStepError(step, start, nil, err)}
+				return nil
+			}
+			readers = append(readers, errR)
+			writers = append(writers, errW)
+			cmd.Stderr = errW
+		}
+		err = cmd.Start()
+		for _, w := range writers {
+			w.Close()
+		}
+		if err != nil {
+			for _, r := range readers {
+				r.Close()
+			}
+			out <- stepLogAndFinishMsg{step: index, err: classifyStepError(step, start, nil, err)}
+			return nil
+		}
+		out <- stepStartedMsg{step: index, pid: cmd.Process.Pid}
+
+		var mu sync.Mutex
+		var tail []string
+		keep := func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			tail = append(tail, line)
+			if len(tail) > STEP_ERROR_TAIL {
+				tail = tail[1:]
+			}
+		}
+
+		var wg sync.WaitGroup
+		for i, r := range readers {
+			wg.Add(1)
+			go func(r *os.File, stderr bool) {
+				defer wg.Done()
+				defer r.Close()
+				scanner := bufio.NewScanner(r)
+				scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+				scanner.Split(scanLinesOrCR)
+				for scanner.Scan() {
+					token := scanner.Text()
+					// Keep bytes that aren't UTF-8 (a Latin-1 path, say) from garbling the pane.
+					line := strings.ToValidUTF8(strings.TrimRight(token, "\r\n"), "\uFFFD")
+					if strings.HasSuffix(token, "\r") {
+						// In-place redraws update the status but don't go in the log.
+						if line != "" {
+							out <- stepProgressMsg{step: index, text: line}
+						}
+						continue
+					}
+					keep(line)
+					out <- stepLineMsg{step: index, text: line, stderr: stderr}
+				}
+			}(r, i == 1)
+		}
+		wg.Wait()
+
+		if err := cmd.Wait(); err != nil {
+			out <- stepLogAndFinishMsg{step: index, err: classifyStepError(step, start, tail, err)}
+			return nil
+		}
+		out <- stepLogAndFinishMsg{step: index}
+		return nil
 	}
 }
 
+func waitForStepMsg(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-ch
+	}
+}
+
+// defaultOptions are the values before any flag, config or preset applies.
+func defaultOptions() options {
+	o := options{
+		serviceScope: "system",
+		serviceArgs:  "--cli",
+		op:           "install",
+		prefix:       DEFAULT_PREFIX,
+		autoLog:      true,
+		runs:         1,
+		sdlVersion:   DEFAULT_SDL_VERSION,
+		renderer:     "sdlgpu",
+		streams:      "combined",
+		logFormat:    "plain",
+		colorProfile: "auto",
+		scrollback:   DEFAULT_SCROLLBACK,
+		reportHead:   DEFAULT_REPORT_HEAD,
+		reportTail:   DEFAULT_REPORT_TAIL,
+		logGzipDays:  DEFAULT_LOG_COMPRESS_DAYS,
+		logKeepDays:  DEFAULT_LOG_KEEP_DAYS,
+		timeFormat:   DEFAULT_TIME_FORMAT,
+		depsTools:    DEPS_CMD,
+		depsPkgs:     DEPS_PKGS,
+	}
+	if inContainer() {
+		// nproc there is the host's, memory is the container's.
+		o.jobs = "auto"
+	}
+	return o
+}
+
+// bindFlags registers the options on fs, using the current values of o as
+// defaults so a fresh FlagSet can be laid over options already resolved.
+func bindFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.timestamps, "timestamps", o.timestamps, "prefix each log line with the time, [HH:MM:SS] unless --time-format says otherwise")
+	fs.StringVar(&o.timeFormat, "time-format", o.timeFormat, "how times are written in the log, history and report: iso (ISO 8601), locale (the date order and clock of LC_TIME) or a strftime `PATTERN` like \"%d.%m.%Y %H:%M\"")
+	fs.BoolVar(&o.installDemos, "install-demos", o.installDemos, "copy the bundled demo carts to ~/.local/share/tic80/carts after installing")
+	fs.BoolVar(&o.registerMime, "register-mime", o.registerMime, "register "+CART_MIME+" for .tic files and make TIC-80 open them by default")
+	fs.StringVar(&o.preInstallHook, "pre-install-hook", o.preInstallHook, "run `SCRIPT` before an install starts")
+	fs.StringVar(&o.postInstallHook, "post-install-hook", o.postInstallHook, "run `SCRIPT` after an install succeeds, with TIC80_PREFIX, TIC80_BINARY and TIC80_VERSION set")
+	fs.StringVar(&o.postUninstallHook, "post-uninstall-hook", o.postUninstallHook, "run `SCRIPT` after an uninstall")
+	fs.BoolVar(&o.installService, "install-service", o.installService, "install and enable a "+SERVICE_NAME+" systemd unit")
+	fs.StringVar(&o.serviceScope, "service-scope", o.serviceScope, "systemd scope for the unit: user or system")
+	fs.StringVar(&o.serviceArgs, "service-args", o.serviceArgs, "arguments passed to tic80 by the service")
+	fs.StringVar(&o.exportScript, "export-script", o.exportScript, "write the steps for --op to `FILE` as a bash script and exit")
+	fs.StringVar(&o.exportDocker, "export-dockerfile", o.exportDocker, "write a multi-stage Containerfile that installs the deps and builds TIC-80 to `FILE` and exit")
+	fs.StringVar(&o.baseImage, "base-image", o.baseImage, "with --export-dockerfile, build on `IMAGE` instead of the one matching this distro")
+	fs.StringVar(&o.op, "op", o.op, "operation for non-interactive modes: install, upgrade, uninstall, reinstall, deps, install-existing or recompile-verbose")
+	fs.BoolVar(&o.inline, "inline", o.inline, "draw the TUI in the terminal instead of the altscreen, so the last screen stays after quitting")
+	fs.BoolVar(&o.autoLog, "auto-log", o.autoLog, "open the log pane at the first error line, or when a step fails (false keeps it closed)")
+	fs.BoolVar(&o.allowRoot, "allow-root", o.allowRoot, "run git, cmake and make as root too, instead of as the user who ran sudo")
+	fs.BoolVar(&o.benchmark, "benchmark", o.benchmark, "time a clean configure and compile of the kept or --source-dir checkout, without installing, and record it in the history")
+	fs.IntVar(&o.runs, "runs", o.runs, "with --benchmark, build `N` times and report the median")
+	fs.BoolVar(&o.headless, "headless", o.headless, "run --op without the TUI")
+	fs.StringVar(&o.prefix, "prefix", o.prefix, "install location passed to CMAKE_INSTALL_PREFIX")
+	fs.StringVar(&o.renderer, "renderer", o.renderer, "renderer to build: sdlgpu (BUILD_SDLGPU) or sdl, SDL's own renderer; try sdl if TIC-80 shows a black screen")
+	fs.StringVar(&o.sdlVersion, "sdl-version", o.sdlVersion, "SDL2 tag checked out in the vendored sdl2 before building")
+	fs.BoolVar(&o.patchSDL, "patch-sdl", o.patchSDL, "check out --sdl-version over the vendored SDL2 (implied by --sdl-version)")
+	fs.StringVar(&o.sandbox, "sandbox", o.sandbox, "run configure and compile inside a sandbox: bwrap")
+	fs.StringVar(&o.wrapper, "wrapper", o.wrapper, "run the compile under `CMD`, e.g. \"nice -n 19 ionice -c3\" to keep the machine responsive")
+	fs.StringVar(&o.colorProfile, "color-profile", o.colorProfile, "terminal colors: auto (from COLORTERM and TERM), truecolor, 256, 16 or none")
+	fs.StringVar(&o.syslog, "syslog", o.syslog, "also send the log to a syslog server at `ADDR` (udp://host:port, tcp://host:port or host), as RFC 5424")
+	fs.StringVar(&o.logFormat, "log-format", o.logFormat, "how the log file is written: plain, ansi (colors kept, errors and warnings colored) or html")
+	fs.StringVar(&o.streams, "streams", o.streams, "combined, or separate to tag lines [out]/[err] and color stderr")
+	fs.BoolVar(&o.detach, "detach", o.detach, "run --op headless in the background and print its PID")
+	fs.IntVar(&o.attach, "attach", o.attach, "follow the log of a detached build with this `PID`")
+	fs.StringVar(&o.controlSocket, "control-socket", o.controlSocket, "instead of the TUI, take start, status and cancel commands as JSON on the Unix socket `PATH`")
+	fs.StringVar(&o.sourceDir, "source-dir", o.sourceDir, "build the TIC-80 checkout in `DIR` instead of cloning it")
+	fs.StringVar(&o.ref, "ref", o.ref, "branch or tag of TIC-80 to build (default: the default branch)")
+	fs.Var(&o.mirrors, "mirror", "also consider cloning TIC-80 from the mirror at `URL`; the fastest to answer git ls-remote of upstream and the mirrors is used (repeatable)")
+	fs.StringVar(&o.label, "label", o.label, "tag the run with `TEXT`, kept in its history entry and report")
+	fs.BoolVar(&o.verifySignature, "verify-signature", o.verifySignature, "fail unless the checked out tag or commit has a good GPG signature")
+	fs.StringVar(&o.trustedKeys, "trusted-keys", o.trustedKeys, "with --verify-signature, trust only the public keys in `FILE` instead of root's keyring")
+	fs.BoolVar(&o.reproducible, "reproducible", o.reproducible, "pin SOURCE_DATE_EPOCH to the commit time and strip build paths")
+	fs.StringVar(&o.jobs, "jobs", o.jobs, "parallel compile jobs: a number, or auto to cap by available memory (default: nproc)")
+	fs.IntVar(&o.submoduleJobs, "submodule-jobs", o.submoduleJobs, "clone without --recursive, then fetch the submodules `N` at a time (0 keeps the recursive clone)")
+	fs.BoolVar(&o.performance, "performance", o.performance, "switch the CPU governor to performance while compiling, then restore it")
+	fs.BoolVar(&o.keepBuild, "keep-build", o.keepBuild, "leave the build tree in place after installing")
+	fs.StringVar(&o.output, "output", o.output, "after compiling, copy the tic80 binary to `FILE`")
+	fs.BoolVar(&o.noInstall, "no-install", o.noInstall, "with --output, skip make install and everything after it")
+	fs.BoolVar(&o.cache, "cache", o.cache, "keep the build tree between runs and skip steps whose inputs are unchanged")
+	fs.StringVar(&o.cacheDir, "cache-dir", o.cacheDir, "keep the build tree and ccache in `DIR`, e.g. a mounted volume, one per distro (implies --cache)")
+	fs.BoolVar(&o.vendorCache, "vendor-cache", o.vendorCache, "build the vendored SDL2 once into "+VENDOR_CACHE_DIR+" and reuse it while its checkout is unchanged")
+	fs.IntVar(&o.scrollback, "scrollback", o.scrollback, "lines of output kept in the log pane, 0 for all")
+	fs.IntVar(&o.reportHead, "report-head", o.reportHead, "lines from the start of each step's output kept in the run report")
+	fs.IntVar(&o.reportTail, "report-tail", o.reportTail, "lines from the end of each step's output kept in the run report")
+	fs.IntVar(&o.logGzipDays, "log-compress-days", o.logGzipDays, "gzip the logs of runs older than `N` days, 0 never")
+	fs.IntVar(&o.logKeepDays, "log-keep-days", o.logKeepDays, "delete the logs of runs older than `N` days, 0 keeps them")
+	fs.StringVar(&o.depsTools, "deps-tools", o.depsTools, "command that installs the compiler toolchain")
+	fs.StringVar(&o.depsPkgs, "deps-pkgs", o.depsPkgs, "command that installs the build libraries")
+	fs.Var(&o.cmakeFlags, "cmake-flag", "extra argument for cmake, overriding the defaults (repeatable)")
+	fs.Var(&o.stepLabels, "step-label", "show the step named `DESC` as a template while it runs, DESC=TEMPLATE with {{.Ref}}, {{.Jobs}}, {{.Distro}}, {{.Prefix}} or {{.Op}} (repeatable)")
+	fs.Var(&o.cmakeFlags, "cmake-arg", "same as --cmake-flag: a -D definition or cmake option, passed verbatim (repeatable)")
+	fs.StringVar(&o.preset, "preset", o.preset, "apply a named build preset: "+strings.Join(presetNames(nil), ", "))
+	fs.BoolVar(&o.compact, "compact", o.compact, "run --op showing a single status line, without the altscreen")
+	fs.BoolVar(&o.dryRun, "dry-run", o.dryRun, "show what would run (and what uninstall would delete) without doing it")
+}
+
+// validateOptions checks and normalizes options once every source of them
+// has been applied.
+func validateOptions(o *options) error {
+	if o.serviceScope != "user" && o.serviceScope != "system" {
+		return fmt.Errorf("--service-scope must be user or system")
+	}
+	if strings.ContainsAny(o.serviceArgs, "\r\n") {
+		return fmt.Errorf("--service-args must be on one line, a line break would end ExecStart in the unit")
+	}
+	if !filepath.IsAbs(o.prefix) {
+		return fmt.Errorf("--prefix must be an absolute path")
+	}
+	o.prefix = filepath.Clean(o.prefix)
+	if o.streams != "combined" && o.streams != "separate" {
+		return fmt.Errorf("--streams must be combined or separate")
+	}
+	if !slices.Contains(LOG_FORMATS, o.logFormat) {
+		return fmt.Errorf("--log-format must be one of %s", strings.Join(LOG_FORMATS, ", "))
+	}
+	if !slices.Contains(colorProfileNames(), o.colorProfile) {
+		return fmt.Errorf("--color-profile must be one of %s", strings.Join(colorProfileNames(), ", "))
+	}
+	if o.syslog != "" {
+		if _, _, err := parseSyslogAddr(o.syslog); err != nil {
+			return err
+		}
+	}
+	if o.renderer != "sdlgpu" && o.renderer != "sdl" {
+		return fmt.Errorf("--renderer must be sdlgpu or sdl")
+	}
+	if o.sandbox != "" && o.sandbox != "bwrap" {
+		return fmt.Errorf("unknown --sandbox %q, only bwrap is supported", o.sandbox)
+	}
+	if !sdlVersionRe.MatchString(o.sdlVersion) {
+		return fmt.Errorf("--sdl-version %q is not an SDL2 release tag like %s", o.sdlVersion, DEFAULT_SDL_VERSION)
+	}
+	if !validJobs(o.jobs) {
+		return fmt.Errorf("--jobs must be a positive number or auto, not %q", o.jobs)
+	}
+	if o.runs < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+	if o.submoduleJobs < 0 {
+		return fmt.Errorf("--submodule-jobs can't be negative")
+	}
+	if o.scrollback < 0 {
+		return fmt.Errorf("--scrollback can't be negative")
+	}
+	for _, s := range o.stepLabels {
+		if _, _, err := parseStepLabel(s); err != nil {
+			return err
+		}
+	}
+	for _, arg := range o.cmakeFlags {
+		if !validCMakeArg(arg) {
+			return fmt.Errorf("--cmake-arg %q is not a -D definition or a cmake option like -U, -G or -Wno-dev", arg)
+		}
+	}
+	if o.noInstall && o.output == "" {
+		return fmt.Errorf("--no-install needs --output, or the build would be thrown away")
+	}
+	for _, path := range []*string{&o.preInstallHook, &o.postInstallHook, &o.postUninstallHook, &o.trustedKeys, &o.output} {
+		if *path != "" {
+			abs, err := filepath.Abs(*path)
+			if err != nil {
+				return err
+			}
+			*path = abs
+		}
+	}
+	if o.cacheDir != "" {
+		abs, err := filepath.Abs(o.cacheDir)
+		if err != nil {
+			return fmt.Errorf("--cache-dir: %v", err)
+		}
+		o.cacheDir = abs
+		o.cache = true
+	}
+	if o.sourceDir != "" {
+		abs, err := filepath.Abs(o.sourceDir)
+		if err != nil {
+			return fmt.Errorf("--source-dir: %v", err)
+		}
+		o.sourceDir = abs
+	}
+	if o.reportHead < 0 || o.reportTail < 0 {
+		return fmt.Errorf("--report-head and --report-tail can't be negative")
+	}
+	if o.logGzipDays < 0 || o.logKeepDays < 0 {
+		return fmt.Errorf("--log-compress-days and --log-keep-days can't be negative")
+	}
+	if err := validTimeFormat(o.timeFormat); err != nil {
+		return err
+	}
+	o.wrapper = strings.TrimSpace(o.wrapper)
+	if err := validWrapper(o.wrapper); err != nil {
+		return err
+	}
+	return nil
+}
+
 func main() {
+	opts := defaultOptions()
+	bindFlags(flag.CommandLine, &opts)
+	level := flag.String("log-level", "normal", "headless output: quiet, normal or debug")
+	configPath := flag.String("config", "", "read options from a JSON `FILE` (- for stdin, which implies --headless)")
+	checkConfig := flag.Bool("check-config", false, "resolve the config and flags, print the effective configuration and any problems, and exit (non-zero on errors)")
+	flag.Parse()
+
+	var custom map[string]preset
+	if *configPath == "" && fileExists(DEFAULT_CONFIG) {
+		*configPath = DEFAULT_CONFIG
+	}
+	if *configPath != "" {
+		var err error
+		if custom, opts.configWarnings, err = loadConfig(*configPath, flag.CommandLine); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// stdin is taken by the config, so there's nothing left to drive a TUI.
+		if *configPath == "-" {
+			if opts.detach {
+				fmt.Println("Error: --detach can't re-read a config from stdin; pass a file instead.")
+				os.Exit(1)
+			}
+			opts.headless = true
+		}
+	}
+	if defs, err := loadDistros(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	} else {
+		distroDefs = defs
+	}
+	// A preset of the same name in the config wins over the file.
+	for _, d := range distroDefs {
+		if _, ok := custom[d.name]; !ok {
+			if custom == nil {
+				custom = map[string]preset{}
+			}
+			custom[d.name] = d.preset()
+		}
+	}
+	opts.presets = custom
+	// Taken before any preset is applied, since applying one sets flags too.
+	opts.explicit = explicitFlags(flag.CommandLine)
+	// With no preset from the flags or the config, a definition for this
+	// distro stands in for one, in every mode.
+	if opts.preset == "" {
+		if d, ok := detectedDistro(); ok {
+			opts.preset = d.name
+		}
+	}
+	if opts.preset != "" {
+		p, ok := lookupPreset(opts.preset, custom)
+		if !ok {
+			fmt.Printf("Error: unknown --preset %q, choose from %s.\n", opts.preset, strings.Join(presetNames(custom), ", "))
+			os.Exit(1)
+		}
+		// Flags and the config file both win over the preset.
+		if err := applyValues("preset "+opts.preset, p.values, flag.CommandLine, opts.explicit); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "sdl-version" {
+			opts.patchSDL = true
+		}
+	})
+	if err := validateOptions(&opts); err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		os.Exit(1)
+	}
+	if opts.cacheDir != "" {
+		useCacheDir(opts.cacheDir)
+	}
+	applyColorProfile(opts.colorProfile)
+	op, ok := parseOperation(opts.op)
+	if !ok {
+		fmt.Printf("Error: unknown --op %q.\n", opts.op)
+		os.Exit(1)
+	}
+	if opts.logLevel, ok = logLevelNames[*level]; !ok {
+		fmt.Printf("Error: unknown --log-level %q.\n", *level)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		os.Exit(runCheckConfig(op, opts, *configPath))
+	}
+	if opts.exportScript != "" {
+		if err := writeScript(opts.exportScript, op, opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Script written to " + opts.exportScript)
+		return
+	}
+	if opts.exportDocker != "" {
+		if err := writeDockerfile(opts.exportDocker, opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Containerfile written to " + opts.exportDocker)
+		return
+	}
+
 	if os.Geteuid() != 0 {
 		fmt.Println("Error: This program must be run as root (sudo).")
 		os.Exit(1)
 	}
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	if notice := rootNotice(opts); notice != "" && (opts.op == "" || buildsBinary(op)) {
+		fmt.Println(notice)
+	}
+	if opts.syslog != "" {
+		// Also before --detach, where the background run's errors can't be seen.
+		w, err := dialSyslog(opts.syslog)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		remoteLog = w
+	}
+	if needsLock(opts) {
+		if err := lockInstance(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.benchmark {
+		code := runBenchmark(opts)
+		remoteLog.flush(SYSLOG_FLUSH_TIMEOUT)
+		os.Exit(code)
+	}
+	if opts.detach {
+		// Taken above so a clash shows here; the background run inherits it.
+		pid, err := detach(instanceLock)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Started %s in the background (PID %d), logging to %s.\n", operationNames[op], pid, LOG_FILE)
+		fmt.Printf("Reattach with: %s --attach %d\n", os.Args[0], pid)
+		return
+	}
+	// Nothing waits for it; a compression cut short by exiting is cleaned up
+	// on the next run.
+	go rotateLogs(opts)
+	if opts.controlSocket != "" {
+		if err := runControlSocket(opts.controlSocket, opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if opts.headless {
+		err := runHeadless(op, opts)
+		remoteLog.flush(SYSLOG_FLUSH_TIMEOUT)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	// Serial consoles and rescue shells draw escape codes as garbage; lipgloss
+	// already drops the colors there, the altscreen has to go too.
+	if term := os.Getenv("TERM"); (term == "" || term == "dumb") && !opts.compact && !opts.inline {
+		if term == "" {
+			term = "unset"
+		}
+		fmt.Println("Note: TERM is " + term + ", drawing inline without the altscreen. Use --headless for plain line output.")
+		opts.inline = true
+	}
+	m := initialModel(opts)
+	var programOpts []tea.ProgramOption
+	if opts.compact {
+		m.pending = op
+		m.steps = getSteps(op, opts)
+	} else if opts.attach != 0 {
+		m.openLogView(LOG_FILE, stateMenu)
+		m.logFollow = true
+	} else {
+		if *configPath == "" && firstRun() {
+			m.state = stateSetup
+			if m.opts.preset == "" {
+				m.opts.preset = detectedPreset()
+			}
+		}
+	}
+	// Inline, the last screen stays in the terminal's scrollback on exit.
+	if !opts.compact && !opts.inline {
+		programOpts = append(programOpts, tea.WithAltScreen())
+	}
+	c := &crash{}
+	p := tea.NewProgram(crashGuard{m: m, crash: c}, programOpts...)
+	c.program = p
+	_, err := p.Run()
+	remoteLog.flush(SYSLOG_FLUSH_TIMEOUT)
+	if c.value != nil {
+		fmt.Println(c.report())
+		os.Exit(1)
+	}
+	if err != nil {
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// useTempState moves the log, history, report and the rest of STATE_DIR into
+// a temporary directory for one test, so a model test never touches them.
+func useTempState(t *testing.T) {
+	dir := t.TempDir()
+	vars := []*string{&STATE_DIR, &HISTORY_FILE, &HISTORY_LOGS, &REPORT_FILE, &LOG_FILE, &CMAKE_CACHE_FILE, &MANIFEST_FILE, &CHECKSUM_FILE, &BUG_REPORT_FILE, &CRASH_LOG, &DEMOS_MANIFEST}
+	old := make([]string, len(vars))
+	for i, v := range vars {
+		old[i] = *v
+		*v = filepath.Join(dir, filepath.Base(*v))
+	}
+	STATE_DIR = dir
+	t.Cleanup(func() {
+		for i, v := range vars {
+			*v = old[i]
+		}
+	})
+}
+
+// drive plays the part of tea.Program for a test: it runs each Cmd on its
+// own goroutine and feeds what they return into Update one at a time, until
+// the run is done.
+func drive(t *testing.T, m model, cmd tea.Cmd) model {
+	t.Helper()
+	msgs := make(chan tea.Msg)
+	run := func(cmd tea.Cmd) {
+		if cmd != nil {
+			go func() { msgs <- cmd() }()
+		}
+	}
+	run(cmd)
+	deadline := time.After(30 * time.Second)
+	for m.state == stateRunning {
+		select {
+		case msg := <-msgs:
+			switch msg := msg.(type) {
+			case tea.BatchMsg:
+				for _, c := range msg {
+					run(c)
+				}
+			case spinner.TickMsg, nil:
+			default:
+				next, cmd := m.Update(msg)
+				m = next.(model)
+				run(cmd)
+			}
+		case <-deadline:
+			t.Fatal("the run didn't finish")
+		}
+	}
+	return m
+}
+
+// TestParallelStepsStream runs several steps side by side, each streaming
+// into the one channel, and checks every line reached its own step in order.
+// Run with -race: the steps' goroutines must only hand messages over, with
+// Update the only one touching the model.
+func TestParallelStepsStream(t *testing.T) {
+	useTempState(t)
+	useTempLock(t)
+	const steps, lines = 4, 200
+	opts := defaultOptions()
+	opts.reportHead = 4 * lines // keep every line
+	m := initialModel(opts)
+	m.pending = actionUninstall
+	for i := 0; i < steps; i++ {
+		m.steps = append(m.steps, installStep{
+			desc:      fmt.Sprintf("Step %d...", i),
+			cmd:       fmt.Sprintf("for n in $(seq %d); do echo %d:out:$n; echo %d:err:$n >&2; done", lines, i, i),
+			dependsOn: []string{},
+		})
+	}
+	m = drive(t, m, m.startRun())
+	if m.err != nil {
+		t.Fatalf("run failed: %v", m.err)
+	}
+	// stdout and stderr are separate pipes, so only each one keeps its order.
+	for i, out := range m.outputs {
+		for _, stream := range []string{"out", "err"} {
+			prefix := fmt.Sprintf("%d:%s:", i, stream)
+			var got []string
+			for _, line := range out.lines() {
+				if _, rest, ok := strings.Cut(line, prefix); ok {
+					got = append(got, rest)
+				}
+			}
+			if len(got) != lines {
+				t.Errorf("step %d std%s: %d lines, want %d", i, stream, len(got), lines)
+				continue
+			}
+			for n, line := range got {
+				if line != fmt.Sprint(n+1) {
+					t.Errorf("step %d std%s: line %d is %q", i, stream, n+1, line)
+					break
+				}
+			}
+		}
+	}
+}
+
+// TestClipboardThroughView checks the OSC 52 fallback reaches the terminal
+// through View, not a write of its own.
+func TestClipboardThroughView(t *testing.T) {
+	m := initialModel(defaultOptions())
+	m.state = stateStepList
+	m.steps = []installStep{{desc: "Testing...", cmd: "echo hi"}}
+	next, _ := m.Update(clipboardMsg{via: "the terminal (OSC 52)", osc52: "echo hi"})
+	if view := next.(model).View(); !strings.Contains(view, ansi.SetSystemClipboard("echo hi")) {
+		t.Errorf("no OSC 52 sequence in the view: %q", view)
+	}
+}
+
+// TestStartRunNoSteps checks an operation with no steps goes straight to
+// the done screen with an error, in the TUI and compact mode, and that every
+// view of it renders.
+func TestStartRunNoSteps(t *testing.T) {
+	useTempState(t)
+	useTempLock(t)
+	for _, compact := range []bool{false, true} {
+		opts := defaultOptions()
+		opts.compact = compact
+		m := initialModel(opts)
+		m.pending = actionInstall
+		m.steps = nil
+		next, cmd := m.Update(startRunMsg{})
+		m = next.(model)
+		want := noStepsError(actionInstall).Error()
+		if m.state != stateDone || m.err == nil || m.err.Error() != want {
+			t.Fatalf("compact %v: state %v, err %v, want %q", compact, m.state, m.err, want)
+		}
+		if compact && cmd == nil {
+			t.Error("compact mode doesn't quit")
+		}
+		if instanceLock != nil {
+			t.Error("lock taken for a run with no steps")
+		}
+		m.View() // before any WindowSizeMsg
+		for _, size := range [][2]int{{80, 24}, {200, 60}} {
+			next, _ := m.Update(tea.WindowSizeMsg{Width: size[0], Height: size[1]})
+			if view := next.(model).View(); !compact && !strings.Contains(view, "no steps defined") {
+				t.Errorf("%dx%d: the error isn't shown:\n%s", size[0], size[1], view)
+			}
+		}
+	}
+}
+
+// TestTaggedStripsCompilerColors feeds tagged gcc and cmake output as it
+// comes with colors forced on, OSC 8 links from -fdiagnostics-urls included.
+func TestTaggedStripsCompilerColors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"gcc error",
+			"\x1b[01m\x1b[K/build/TIC-80/src/core/core.c:210:5:\x1b[m\x1b[K \x1b[01;31m\x1b[Kerror: \x1b[m\x1b[Kimplicit declaration of function '\x1b[01m\x1b[Ktic_tick\x1b[m\x1b[K'",
+			"/build/TIC-80/src/core/core.c:210:5: error: implicit declaration of function 'tic_tick'"},
+		{"gcc warning with a link",
+			"\x1b[01;35m\x1b[Kwarning: \x1b[m\x1b[Kunused variable 'x' [\x1b[01;35m\x1b[K\x1b]8;;https://gcc.gnu.org/onlinedocs/gcc/Warning-Options.html#index-Wunused-variable\x07-Wunused-variable\x1b]8;;\x07\x1b[m\x1b[K]",
+			"warning: unused variable 'x' [-Wunused-variable]"},
+		{"gcc caret line",
+			"  210 |     \x1b[01;31m\x1b[Ktic_tick\x1b[m\x1b[K(core);",
+			"  210 |     tic_tick(core);"},
+		{"cmake", "\x1b[1;31mCMake Error\x1b[0m at CMakeLists.txt:10 (find_package):",
+			"CMake Error at CMakeLists.txt:10 (find_package):"},
+		{"make progress", "[ 42%] \x1b[32mBuilding C object CMakeFiles/tic80core.dir/src/core/core.c.o\x1b[0m",
+			"[ 42%] Building C object CMakeFiles/tic80core.dir/src/core/core.c.o"},
+		{"plain", "no colors here", "no colors here"},
+	}
+	opts := options{}
+	separate := options{streams: "separate"}
+	for _, tt := range tests {
+		if got := (stepLineMsg{text: tt.text}).tagged(opts); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if got := (stepLineMsg{text: tt.text, stderr: true}).tagged(separate); got != "[err] "+tt.want {
+			t.Errorf("%s, separate: got %q", tt.name, got)
+		}
+		if got := (stepLineMsg{text: tt.text}).tagged(separate); got != "[out] "+tt.want {
+			t.Errorf("%s, separate stdout: got %q", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// --- CART FILE ASSOCIATION ---
+
+const CART_MIME = "application/x-tic80-cart"
+
+const CART_MIME_XML = `<?xml version="1.0" encoding="UTF-8"?>
+<mime-info xmlns="http://www.freedesktop.org/standards/shared-mime-info">
+  <mime-type type="application/x-tic80-cart">
+    <comment>TIC-80 cartridge</comment>
+    <glob pattern="*.tic"/>
+  </mime-type>
+</mime-info>`
+
+func mimeDir(opts options) string {
+	return filepath.Join(opts.prefix, "share/mime")
+}
+
+func mimePackagePath(opts options) string {
+	return filepath.Join(mimeDir(opts), "packages/tic80.xml")
+}
+
+// withCartOwner runs cmd as the user who ran sudo, whose mimeapps.list holds
+// the default application, or as root without one.
+func withCartOwner(cmd string) string {
+	if owner := demosOwner(); owner != "" {
+		return asUser(owner, cmd)
+	}
+	return cmd
+}
+
+// registerMimeStep installs the cart MIME type for *.tic and makes the
+// installed tic80.desktop its default application.
+func registerMimeStep(opts options) installStep {
+	return installStep{
+		desc: "Registering .tic files...",
+		cmd: fmt.Sprintf("mkdir -p %s && printf '%%s\\n' %s > %s && update-mime-database %s && %s",
+			shellQuote(filepath.Dir(mimePackagePath(opts))), shellQuote(CART_MIME_XML), shellQuote(mimePackagePath(opts)),
+			shellQuote(mimeDir(opts)), withCartOwner("xdg-mime default tic80.desktop "+CART_MIME)),
+		nonFatal: true,
+	}
+}
+
+// unregisterMimeStep undoes registerMimeStep, if it ran.
+func unregisterMimeStep(opts options) installStep {
+	unsetDefault := fmt.Sprintf(`f="${XDG_CONFIG_HOME:-$HOME/.config}/mimeapps.list"; [ ! -f "$f" ] || sed -i '\|^%s=|d' "$f"`, CART_MIME)
+	return installStep{
+		desc: "Removing .tic association...",
+		cmd: fmt.Sprintf("if [ -f %s ]; then rm -f %s && update-mime-database %s && %s; fi",
+			shellQuote(mimePackagePath(opts)), shellQuote(mimePackagePath(opts)), shellQuote(mimeDir(opts)), withCartOwner(unsetDefault)),
+		nonFatal: true,
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- CLONE MIRRORS ---
+
+// MIRROR_PROBE_TIMEOUT is how long, in seconds, a mirror gets to answer
+// git ls-remote before it is left out.
+const MIRROR_PROBE_TIMEOUT = 10
+
+// probeMirrors is shell that times a git ls-remote of HEAD on upstream and
+// each --mirror, one after the other, and leaves the fastest that answered
+// in $repo; upstream if none did.
+func probeMirrors(opts options) string {
+	urls := []string{shellQuote(TIC80_REPO)}
+	for _, m := range opts.mirrors {
+		urls = append(urls, shellQuote(m))
+	}
+	return fmt.Sprintf(`repo=%s; best=; for url in %s; do start=$(date +%%s%%N); `+
+		`if GIT_TERMINAL_PROMPT=0 timeout %d git ls-remote "$url" HEAD >/dev/null 2>&1; then ms=$(( ($(date +%%s%%N) - start) / 1000000 )); echo "$url answered in $ms ms"; `+
+		`if [ -z "$best" ] || [ "$ms" -lt "$best" ]; then repo=$url; best=$ms; fi; `+
+		`else echo "$url did not answer, leaving it out"; fi; done; echo "Cloning from $repo"`,
+		shellQuote(TIC80_REPO), strings.Join(urls, " "), MIRROR_PROBE_TIMEOUT)
+}
+
+// cloneFromMirror is clone(TIC80_REPO), or with --mirror the clone from the
+// fastest of upstream and the mirrors. A mirror clone that fails is removed
+// and upstream cloned instead; one that works gets upstream as its origin,
+// so fetches, resumes and --cache updates later go where they always did.
+func cloneFromMirror(clone func(repo string) string, opts options) string {
+	if len(opts.mirrors) == 0 {
+		return clone(TIC80_REPO)
+	}
+	src, upstream := shellQuote(SRC_DIR), shellQuote(TIC80_REPO)
+	return fmt.Sprintf(`{ %s; if [ "$repo" = %s ]; then %s; `+
+		`else { %s && git -C %s remote set-url origin %s; } || { echo "Cloning from the mirror failed, cloning from upstream instead." >&2; rm -rf %s && %s; }; fi; }`,
+		probeMirrors(opts), upstream, clone(TIC80_REPO), clone(`"$repo"`), src, upstream, src, clone(TIC80_REPO))
+}
+
+// mirrorSummary is the Mirrors row of the summary.
+func mirrorSummary(opts options) string {
+	return fmt.Sprintf("fastest of upstream and %d by git ls-remote, upstream if a mirror fails", len(opts.mirrors))
+}
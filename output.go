@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+// --- BINARY EXPORT ---
+
+// exportBinaryStep copies the tic80 built under src to --output, so it can be
+// handed around or tried out without installing it.
+func exportBinaryStep(src string, opts options) installStep {
+	return installStep{desc: "Exporting Binary...", cmd: fmt.Sprintf("install -D -m 755 %s %s && echo 'Copied to '%s",
+		shellQuote(src+"/build/bin/tic80"), shellQuote(opts.output), shellQuote(opts.output))}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- PARTIAL INSTALL CHECK ---
+
+// CMake's install_manifest.txt from the last install, saved by its step.
+var MANIFEST_FILE = filepath.Join(STATE_DIR, "install-manifest.txt")
+
+// partialInstallMsg lists the files missing from an install that is only
+// partly there; it is empty when the install is whole or absent.
+type partialInstallMsg struct {
+	missing []string
+}
+
+// installedFiles is what an install under opts.prefix should have put in
+// place: the saved manifest if it is for this prefix, otherwise the files
+// uninstall knows about.
+func installedFiles(opts options) []string {
+	if data, err := os.ReadFile(MANIFEST_FILE); err == nil {
+		files := strings.Fields(string(data))
+		if slices.Contains(files, binPath(opts.prefix)) {
+			return files
+		}
+	}
+	var files []string
+	for _, t := range uninstallTargets(opts) {
+		files = append(files, t.path)
+	}
+	return files
+}
+
+func checkPartialInstall(opts options) tea.Cmd {
+	return func() tea.Msg {
+		var present, missing []string
+		for _, path := range installedFiles(opts) {
+			if fileExists(path) {
+				present = append(present, path)
+			} else {
+				missing = append(missing, path)
+			}
+		}
+		if len(present) == 0 {
+			return partialInstallMsg{}
+		}
+		return partialInstallMsg{missing: missing}
+	}
+}
+
+// repairAction reinstalls from a kept build when there is one, and rebuilds
+// otherwise.
+func repairAction() action {
+	if fileExists(SRC_DIR + "/build/bin/tic80") {
+		return actionInstallExisting
+	}
+	return actionUpgrade
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- PATH CHECK ---
+
+type pathCheckMsg struct {
+	warning string
+}
+
+// userPath returns the PATH the invoking user gets in a login shell. sudo
+// swaps in its own secure_path, so our environment isn't representative.
+func userPath() string {
+	if user := os.Getenv("SUDO_USER"); user != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "su", "-", user, "-c", `printf %s "$PATH"`).Output()
+		if err == nil && len(out) > 0 {
+			return string(out)
+		}
+	}
+	return os.Getenv("PATH")
+}
+
+// pathWarning explains how to fix PATH if the installed binary's directory
+// isn't on it, or returns "" when it is.
+func pathWarning(opts options) string {
+	binDir := filepath.Dir(binPath(opts.prefix))
+	for _, dir := range strings.Split(userPath(), ":") {
+		if filepath.Clean(dir) == binDir {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s is not on your PATH. Add this to your shell rc:\nexport PATH=\"%s:$PATH\"", binDir, binDir)
+}
+
+func checkPath(opts options) tea.Cmd {
+	return func() tea.Msg {
+		return pathCheckMsg{warning: pathWarning(opts)}
+	}
+}
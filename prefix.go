@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- INSTALL LOCATION ---
+
+// userHome is the home of the build user, or our own without one, which is
+// where ~ in a prefix points.
+func userHome(opts options) string {
+	if name := buildUser(opts); name != "" {
+		if u, err := user.Lookup(name); err == nil {
+			return u.HomeDir
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// prefixChoices are the install locations the picker offers before Custom.
+func prefixChoices(opts options) []string {
+	return []string{DEFAULT_PREFIX, "/usr", filepath.Join(userHome(opts), ".local"), "/opt/tic80"}
+}
+
+// expandPrefix turns what was typed for a custom location into a prefix.
+func expandPrefix(opts options, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = userHome(opts) + s[1:]
+	}
+	if !filepath.IsAbs(s) {
+		return "", errors.New("give an absolute path, like /opt/tic80 or ~/.local")
+	}
+	return filepath.Clean(s), nil
+}
+
+// prefixOwner is the build user when they can write prefix themselves, ""
+// when installing there takes root.
+func prefixOwner(opts options, prefix string) string {
+	name := buildUser(opts)
+	if name == "" {
+		return ""
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(existingParent(prefix))
+	if err != nil {
+		return ""
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	mode := fi.Mode().Perm()
+	groups, _ := u.GroupIds()
+	switch {
+	case strconv.FormatUint(uint64(st.Uid), 10) == u.Uid:
+		ok = mode&0200 != 0
+	case slices.Contains(groups, strconv.FormatUint(uint64(st.Gid), 10)):
+		ok = mode&0020 != 0
+	default:
+		ok = mode&0002 != 0
+	}
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// rootNote says whether installing to prefix takes root.
+func rootNote(opts options, prefix string) string {
+	if owner := prefixOwner(opts, prefix); owner != "" {
+		return "no root needed, files owned by " + owner
+	}
+	return "needs root"
+}
+
+// prefixNote is rootNote, or why prefix can't be used at all.
+func prefixNote(opts options, prefix string) string {
+	if err := writableDir(prefix); err != nil {
+		return "not usable, " + err.Error()
+	}
+	return rootNote(opts, prefix)
+}
+
+// chownInstalled gives the files the install wrote back to the build user
+// when the prefix is theirs, so a ~/.local install isn't stuck as root's.
+// It goes by install_manifest.txt, in the build dir the install ran in, so
+// whatever else under the prefix belongs to root stays root's.
+func chownInstalled(opts options) string {
+	owner := prefixOwner(opts, opts.prefix)
+	if owner == "" {
+		return ""
+	}
+	return fmt.Sprintf("xargs -rd '\\n' chown -h %s: -- < install_manifest.txt", shellQuote(owner))
+}
+
+// prefixPicker is the setting for the prefix; Enter opens the picker.
+func prefixPicker(label string) setting {
+	return setting{
+		label: label,
+		value: func(o options) string { return o.prefix + " (" + rootNote(o, o.prefix) + ")" },
+		keep:  func(to *options, from options) { to.prefix = from.prefix },
+		open:  statePrefixPick,
+	}
+}
+
+// openPrefixPick shows the picker with the cursor on the current prefix, or
+// on Custom for one that isn't in the list. The notes are worked out once
+// here, since each one tries writing a file.
+func (m *model) openPrefixPick(back state) {
+	choices := prefixChoices(m.opts)
+	m.prefixNotes = make([]string, len(choices))
+	for i, prefix := range choices {
+		m.prefixNotes[i] = prefixNote(m.opts, prefix)
+	}
+	m.prefixBack = back
+	m.prefixErr = ""
+	m.prefixRow = len(choices)
+	if i := slices.Index(choices, m.opts.prefix); i >= 0 {
+		m.prefixRow = i
+	}
+	m.state = statePrefixPick
+}
+
+// pickPrefix sets the prefix if it can be used, otherwise stays on the
+// picker with the reason.
+func (m *model) pickPrefix(prefix string) {
+	if err := writableDir(prefix); err != nil {
+		m.prefixErr = fmt.Sprintf("Can't install to %s: %v.", prefix, err)
+		return
+	}
+	m.opts.prefix = prefix
+	if m.prefixBack == stateSettings {
+		m.edited[m.setCursor] = true
+	}
+	m.state = m.prefixBack
+}
+
+// updatePrefixInput edits the custom location; it takes every key, so
+// letters go into the path instead of working as shortcuts.
+func (m model) updatePrefixInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.state = statePrefixPick
+		m.prefixErr = ""
+	case tea.KeyEnter:
+		prefix, err := expandPrefix(m.opts, m.prefixInput)
+		if err != nil {
+			m.prefixErr = "Can't use that: " + err.Error() + "."
+			return m, nil
+		}
+		m.pickPrefix(prefix)
+	case tea.KeyBackspace:
+		if r := []rune(m.prefixInput); len(r) > 0 {
+			m.prefixInput = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.prefixInput += string(msg.Runes)
+	}
+	return m, nil
+}
+
+func renderPrefixPick(opts options, notes []string, cursor int, input string, editing bool, errMsg string) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Where should TIC-80 be installed?") + "\n\n")
+	choices := prefixChoices(opts)
+	custom := "Custom..."
+	if !slices.Contains(choices, opts.prefix) {
+		custom = "Custom: " + opts.prefix
+	}
+	for i, row := range append(choices, custom) {
+		if i < len(notes) {
+			row += strings.Repeat(" ", max(1, 22-len(row))) + notes[i]
+		}
+		if i == cursor {
+			s.WriteString(" " + styleError.Render(">█ ") + styleSelected.Render(row) + "\n")
+		} else {
+			s.WriteString("    " + styleNormal.Render(row) + "\n")
+		}
+	}
+	if editing {
+		s.WriteString("\n " + styleNormal.Render("Path: "+input+"█") + "\n")
+	}
+	if errMsg != "" {
+		s.WriteString("\n " + styleError.Render(errMsg) + "\n")
+	}
+	if editing {
+		s.WriteString("\n " + styleLog.Render("Type the prefix (~ is "+userHome(opts)+"), Enter to use it, Esc to go back"))
+	} else {
+		s.WriteString("\n " + styleLog.Render("The binary goes to PREFIX/bin. Enter picks the location, Esc to go back"))
+	}
+	return s.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// TestChownInstalledOnlyManifest installs into a prefix owned by the build
+// user and checks only the files in install_manifest.txt change hands.
+func TestChownInstalledOnlyManifest(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to chown")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+	t.Setenv("SUDO_USER", "nobody")
+	uid, _ := strconv.Atoi(nobody.Uid)
+
+	prefix := filepath.Join(t.TempDir(), "a b")
+	buildDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(prefix, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(prefix, uid, -1); err != nil {
+		t.Fatal(err)
+	}
+	installed := filepath.Join(prefix, "bin", `tic80 $x'q"`)
+	other := filepath.Join(prefix, "bin", "other")
+	for _, f := range []string{installed, other} {
+		if err := os.WriteFile(f, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(buildDir, "install_manifest.txt"), []byte(installed+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := defaultOptions()
+	opts.prefix = prefix
+	cmd := chownInstalled(opts)
+	if cmd == "" {
+		t.Fatal("no chown for a prefix the build user owns")
+	}
+	c := exec.Command("bash", "-c", cmd)
+	c.Dir = buildDir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("%s: %v\n%s", cmd, err, out)
+	}
+	owner := func(path string) int {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(fi.Sys().(*syscall.Stat_t).Uid)
+	}
+	if got := owner(installed); got != uid {
+		t.Errorf("installed file owned by %d, want %d", got, uid)
+	}
+	if got := owner(other); got != 0 {
+		t.Errorf("file outside the manifest owned by %d, want root", got)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- PREFLIGHT ---
+
+// preflightMsg reports checks that run before the first step. An error
+// aborts the run; warnings are only logged.
+type preflightMsg struct {
+	err      error
+	warnings []string
+}
+
+func runPreflight(a action, opts options) tea.Cmd {
+	return func() tea.Msg {
+		warnings, err := preflight(a, opts)
+		return preflightMsg{err: err, warnings: warnings}
+	}
+}
+
+func preflight(a action, opts options) ([]string, error) {
+	warnings := append([]string(nil), opts.configWarnings...)
+	if err := checkHooks(opts); err != nil {
+		return warnings, err
+	}
+	if !buildsBinary(a) {
+		return warnings, nil
+	}
+	if !opts.noInstall {
+		if err := checkWritable(opts.prefix); err != nil {
+			return warnings, err
+		}
+	}
+	if opts.output != "" {
+		if err := writableDir(filepath.Dir(opts.output)); err != nil {
+			return warnings, fmt.Errorf("cannot write --output %s: %v", opts.output, err)
+		}
+	}
+	if a != actionInstallExisting {
+		if err := checkRootBuild(opts); err != nil {
+			return warnings, err
+		}
+		if err := checkSignatureSetup(opts); err != nil {
+			return warnings, err
+		}
+	}
+	if opts.sourceDir != "" {
+		warning, err := checkSourceDir(opts.sourceDir)
+		if err != nil {
+			return warnings, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if opts.ref != "" || opts.patchSDL {
+			warnings = append(warnings, "--ref and --patch-sdl are ignored with --source-dir, the tree is built as it is")
+		}
+	} else if opts.ref != "" {
+		if err := checkRef(TIC80_REPO, opts.ref); err != nil {
+			return warnings, err
+		}
+	}
+	if a != actionInstallExisting {
+		if warning := jobsWarning(opts); warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if warning := governorWarning(opts); warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if warning := containerWarning(opts); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if warning := selinuxWarning(opts); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if opts.sandbox != "" && !sandboxAvailable(opts) {
+		warnings = append(warnings, "bwrap not found, building without a sandbox (install the bubblewrap package)")
+	}
+	return warnings, nil
+}
+
+// checkWritable creates and removes a temp file under the install prefix, or
+// its nearest existing parent if make install would create it. This catches
+// read-only and immutable mounts before a full compile instead of after.
+func checkWritable(prefix string) error {
+	if err := writableDir(prefix); err != nil {
+		return fmt.Errorf("cannot install to %s: %v. Choose another location with --prefix (e.g. --prefix /opt/tic80)", prefix, err)
+	}
+	return nil
+}
+
+// writableDir tries a temp file in dir, or its nearest existing parent.
+func writableDir(dir string) error {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".tic80-manager-write-test-*")
+	if err != nil {
+		if errors.Is(err, syscall.EROFS) {
+			return errors.New("it is on a read-only filesystem")
+		}
+		return errors.New("it is not writable")
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"sort"
+)
+
+// --- PRESETS ---
+
+// A preset is a named set of option values, applied like a config file but
+// below it: anything from the command line or --config wins.
+type preset struct {
+	desc   string
+	values map[string]any
+}
+
+const DEBIAN_TOOLS = "apt-get -y install build-essential"
+const DEBIAN_PKGS = "apt-get -y install cmake git ruby-full rake libglvnd-dev libglu1-mesa-dev freeglut3-dev libasound2-dev libx11-dev libxext-dev libxcursor-dev libxi-dev libxrandr-dev libcurl4-openssl-dev curl"
+
+var builtinPresets = map[string]preset{
+	"fedora-default": {"Fedora, dnf, full Pro build (the defaults)", map[string]any{
+		"deps-tools": DEPS_CMD,
+		"deps-pkgs":  DEPS_PKGS,
+	}},
+	"debian-cli": {"Debian/Ubuntu, apt, without the SDL_gpu renderer", map[string]any{
+		"deps-tools": DEBIAN_TOOLS,
+		"deps-pkgs":  DEBIAN_PKGS,
+		"renderer":   "sdl",
+	}},
+	// SDL's own renderer runs on the Pi's GLES driver; SDL_gpu wants desktop GL.
+	"pi-gles": {"Raspberry Pi OS, GLES through SDL, jobs capped by memory", map[string]any{
+		"deps-tools": DEBIAN_TOOLS,
+		"deps-pkgs":  DEBIAN_PKGS + " libgles2-mesa-dev libegl1-mesa-dev",
+		"renderer":   "sdl",
+		"jobs":       "auto",
+	}},
+	"static-minimal": {"Fedora, Lua only, no demo carts, size-optimized", map[string]any{
+		"deps-tools": DEPS_CMD,
+		"deps-pkgs":  DEPS_PKGS,
+		"cmake-flag": []any{"-DBUILD_WITH_ALL=Off", "-DBUILD_WITH_LUA=On", "-DBUILD_DEMO_CARTS=Off", "-DCMAKE_BUILD_TYPE=MinSizeRel"},
+	}},
+}
+
+// lookupPreset prefers a preset from the config over a built-in one.
+func lookupPreset(name string, custom map[string]preset) (preset, bool) {
+	if p, ok := custom[name]; ok {
+		return p, true
+	}
+	p, ok := builtinPresets[name]
+	return p, ok
+}
+
+func presetNames(custom map[string]preset) []string {
+	var names []string
+	for name := range builtinPresets {
+		names = append(names, name)
+	}
+	for name := range custom {
+		if _, ok := builtinPresets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPreset lays preset name over opts, for picking one from the menu; opts
+// should be the options the program started with, so picks don't stack.
+// The preset opts already has is taken off first. Flags given on the command
+// line or in --config still win.
+func withPreset(opts options, name string) (options, error) {
+	p, _ := lookupPreset(name, opts.presets)
+	o := opts
+	fs := flag.NewFlagSet("preset", flag.ContinueOnError)
+	bindFlags(fs, &o)
+	// Copy so appending to the repeatable flag can't touch opts.
+	o.cmakeFlags = append(stringList(nil), opts.cmakeFlags...)
+	if old, ok := lookupPreset(opts.preset, opts.presets); ok {
+		clearPreset(&o, old, fs)
+	}
+	if err := applyValues("preset "+name, p.values, fs, opts.explicit); err != nil {
+		return opts, err
+	}
+	if _, ok := p.values["sdl-version"]; ok {
+		o.patchSDL = true
+	}
+	if err := validateOptions(&o); err != nil {
+		return opts, err
+	}
+	o.preset = name
+	return o, nil
+}
+
+// clearPreset puts the options p set back to their defaults, except those
+// given explicitly. fs must be bound to o.
+func clearPreset(o *options, p preset, fs *flag.FlagSet) {
+	def := defaultOptions()
+	defs := flag.NewFlagSet("defaults", flag.ContinueOnError)
+	bindFlags(defs, &def)
+	for key := range p.values {
+		f := fs.Lookup(key)
+		if f == nil || o.explicit[key] {
+			continue
+		}
+		if list, ok := f.Value.(*stringList); ok {
+			*list = append(stringList(nil), *defs.Lookup(key).Value.(*stringList)...)
+		} else {
+			f.Value.Set(defs.Lookup(key).Value.String())
+		}
+		if key == "sdl-version" && !o.explicit["patch-sdl"] {
+			o.patchSDL = def.patchSDL
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestWithPresetReplaces picks presets one after another, the way the menu
+// does, and checks each pick replaces the last instead of stacking on it.
+func TestWithPresetReplaces(t *testing.T) {
+	start := defaultOptions()
+	start.explicit = map[string]bool{"prefix": true}
+	start.prefix = "/opt/tic80"
+	opts, err := withPreset(start, "debian-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.renderer != "sdl" || opts.depsTools != DEBIAN_TOOLS {
+		t.Fatalf("debian-cli: renderer %q, deps-tools %q", opts.renderer, opts.depsTools)
+	}
+	for _, name := range []string{"static-minimal", "fedora-default"} {
+		if opts, err = withPreset(opts, name); err != nil {
+			t.Fatal(err)
+		}
+		if opts.preset != name || opts.renderer != "sdlgpu" || opts.depsTools != DEPS_CMD || opts.prefix != "/opt/tic80" {
+			t.Errorf("%s after debian-cli: preset %q, renderer %q, deps-tools %q, prefix %q", name, opts.preset, opts.renderer, opts.depsTools, opts.prefix)
+		}
+	}
+	if len(opts.cmakeFlags) != 0 {
+		t.Errorf("static-minimal's cmake flags outlived it: %v", opts.cmakeFlags)
+	}
+}
+
+// TestWithPresetExplicit checks flags from the command line or the config
+// win over every preset, including the one the program started with.
+func TestWithPresetExplicit(t *testing.T) {
+	start := defaultOptions()
+	start.explicit = map[string]bool{"renderer": true, "cmake-arg": true, "cmake-flag": true}
+	start.cmakeFlags = stringList{"-DBUILD_WITH_MRUBY=Off"}
+	opts, err := withPreset(start, "pi-gles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.renderer != "sdlgpu" || opts.jobs != "auto" {
+		t.Fatalf("pi-gles: renderer %q, jobs %q", opts.renderer, opts.jobs)
+	}
+	if opts, err = withPreset(opts, "static-minimal"); err != nil {
+		t.Fatal(err)
+	}
+	if opts.renderer != "sdlgpu" || opts.jobs != defaultOptions().jobs {
+		t.Errorf("static-minimal: renderer %q, jobs %q", opts.renderer, opts.jobs)
+	}
+	if !slices.Equal(opts.cmakeFlags, start.cmakeFlags) {
+		t.Errorf("cmake flags %v, want %v", opts.cmakeFlags, start.cmakeFlags)
+	}
+}
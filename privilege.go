@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// --- BUILD USER ---
+
+// buildUser is who runs the steps that don't need root (git, cmake, make):
+// the user who started us with sudo, so the clone, the configure and the
+// compile run with that user's rights rather than root's. It keeps mistakes
+// and careless scripts away from the system, but it isn't a sandbox: the
+// install still runs as root from a tree that user owns, so a hostile build
+// script or submodule can change what gets installed, or run its own
+// commands at install time. "" means root runs everything, as with
+// --allow-root or without a sudo user to fall back to.
+func buildUser(opts options) string {
+	name := os.Getenv("SUDO_USER")
+	if opts.allowRoot || geteuid() != 0 || name == "" || name == "root" {
+		return ""
+	}
+	if _, err := user.Lookup(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+// asBuildUser runs step as the build user, with their HOME so git and gpg
+// read that user's config and keyring instead of failing on root's.
+func asBuildUser(step installStep, opts options) installStep {
+	name := buildUser(opts)
+	if name == "" {
+		return step
+	}
+	step.cmd = asUser(name, step.cmd)
+	return step
+}
+
+// asUser wraps cmd to run as the user name.
+func asUser(name, cmd string) string {
+	home := "/"
+	if u, err := user.Lookup(name); err == nil {
+		home = u.HomeDir
+	}
+	return fmt.Sprintf("runuser -u %s -- env HOME=%s bash -c %s", shellQuote(name), shellQuote(home), shellQuote(cmd))
+}
+
+// handOver is a root command that creates dirs and gives them to the build
+// user, "" without one. It takes back anything an earlier root build left.
+func handOver(opts options, dirs ...string) string {
+	name := buildUser(opts)
+	if name == "" || len(dirs) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = shellQuote(dir)
+	}
+	list := strings.Join(quoted, " ")
+	return fmt.Sprintf("mkdir -p %s && chown -R %s: %s", list, shellQuote(name), list)
+}
+
+// handedOver prefixes step with handOver for dirs, after any wrapping, so
+// the chown itself runs as root.
+func handedOver(step installStep, opts options, dirs ...string) installStep {
+	if cmd := handOver(opts, dirs...); cmd != "" {
+		step.cmd = cmd + " && " + step.cmd
+	}
+	return step
+}
+
+// rootNotice is printed before a build that hands the tree to the sudo user.
+func rootNotice(opts options) string {
+	if geteuid() != 0 || opts.allowRoot {
+		return ""
+	}
+	if name := buildUser(opts); name != "" {
+		return fmt.Sprintf("Note: git, cmake and make will run as %s, only installing runs as root. Pass --allow-root to run every step as root.", name)
+	}
+	return ""
+}
+
+// checkRootBuild refuses to build as root only because there is no sudo
+// user to build as; --allow-root (Build as root in Settings) accepts it.
+func checkRootBuild(opts options) error {
+	if geteuid() != 0 || opts.allowRoot || buildUser(opts) != "" {
+		return nil
+	}
+	return errors.New("running as root with no sudo user to build as, so git, cmake and make would run as root too; start from your own account with sudo, or pass --allow-root (Build as root in Settings) to accept this")
+}
+
+func buildUserSummary(opts options) string {
+	if name := buildUser(opts); name != "" {
+		return name + ", root only to install"
+	}
+	return "root"
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRootBuild(t *testing.T) {
+	old := geteuid
+	t.Cleanup(func() { geteuid = old })
+
+	tests := []struct {
+		name      string
+		uid       int
+		sudoUser  string
+		allowRoot bool
+		refused   bool
+	}{
+		{"root without a sudo user", 0, "", false, true},
+		{"sudo from root", 0, "root", false, true},
+		{"root with --allow-root", 0, "", true, false},
+		{"sudo from a user", 0, "nobody", false, false},
+		{"not root", 1000, "", false, false},
+	}
+	for _, tt := range tests {
+		uid := tt.uid
+		geteuid = func() int { return uid }
+		t.Setenv("SUDO_USER", tt.sudoUser)
+		err := checkRootBuild(options{allowRoot: tt.allowRoot})
+		if (err != nil) != tt.refused {
+			t.Errorf("%s: checkRootBuild = %v, want refused %v", tt.name, err, tt.refused)
+		}
+		if err != nil && !strings.Contains(err.Error(), "--allow-root") {
+			t.Errorf("%s: error %q doesn't mention --allow-root", tt.name, err)
+		}
+	}
+}
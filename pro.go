@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+)
+
+// --- PRO CHECKS ---
+
+// proFlagsStep confirms TIC80_PRO made it into the compile flags CMake
+// generated, whether from BUILD_PRO or the forced CMAKE_C_FLAGS. Without it
+// the build would quietly be the free version.
+func proFlagsStep(src string) installStep {
+	return installStep{
+		desc: "Verifying Pro flags...",
+		cmd: fmt.Sprintf(`if grep -rqs -- -DTIC80_PRO %s --include=flags.make; then echo "TIC80_PRO is defined in the generated build files."; `+
+			`else echo "WARNING: TIC80_PRO is not defined in the generated build files, this would build the FREE version of TIC-80." >&2; exit 1; fi`,
+			shellQuote(src+"/build/CMakeFiles")),
+		nonFatal: true,
+	}
+}
+
+// proBinaryStep checks that bin calls itself Pro: Pro builds append " Pro"
+// to the version string. --version is bounded like probeVersion's.
+func proBinaryStep(bin string) installStep {
+	return installStep{
+		desc: "Checking Pro binary...",
+		cmd: fmt.Sprintf(`out=$(timeout %d %s --version 2>&1 </dev/null); echo "$out"; `+
+			`case "$out" in *Pro*) echo "The binary reports a Pro build." ;; `+
+			`"") echo "WARNING: "%s" --version did not answer, so the Pro build could not be confirmed." >&2; exit 1 ;; `+
+			`*) echo "WARNING: "%s" does not report a Pro build, it may be the FREE version." >&2; exit 1 ;; esac`,
+			int(VERSION_PROBE_TIMEOUT.Seconds()), shellQuote(bin), shellQuote(bin), shellQuote(bin)),
+		nonFatal: true,
+	}
+}
+
+// LAUNCH_CHECK_SECONDS is how long launchStep lets TIC-80 run.
+var LAUNCH_CHECK_SECONDS = 5
+
+// launchStep starts bin on SDL's offscreen video driver, so a renderer that
+// can't start on this machine shows up as a warning now rather than when
+// TIC-80 is first opened. Still running when timeout stops it counts as
+// launched. HOME is a throwaway directory, so the run leaves no config.
+func launchStep(bin string) installStep {
+	return installStep{
+		desc: "Launching TIC-80...",
+		cmd: fmt.Sprintf(`home=$(mktemp -d) || exit 1; HOME=$home XDG_DATA_HOME=$home SDL_VIDEODRIVER=offscreen SDL_AUDIODRIVER=dummy timeout %d %s --skip </dev/null; rc=$?; rm -rf "$home"; `+
+			`if [ $rc -eq 124 ]; then echo "TIC-80 started and was still running after %d seconds."; `+
+			`else echo "WARNING: "%s" exited with status $rc within %d seconds of starting, its renderer may not work here; if it doesn't open either, rebuild with --renderer sdl." >&2; exit 1; fi`,
+			LAUNCH_CHECK_SECONDS, shellQuote(bin), LAUNCH_CHECK_SECONDS, shellQuote(bin), LAUNCH_CHECK_SECONDS),
+		nonFatal: true,
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestLaunchStep runs the launch check against stand-ins for tic80: one
+// that keeps running, one that exits straight away.
+func TestLaunchStep(t *testing.T) {
+	old := LAUNCH_CHECK_SECONDS
+	LAUNCH_CHECK_SECONDS = 1
+	t.Cleanup(func() { LAUNCH_CHECK_SECONDS = old })
+	dir := t.TempDir()
+	tests := []struct {
+		name, script string
+		ok           bool
+	}{
+		{"keeps running", `echo "$HOME $SDL_VIDEODRIVER" >` + shellQuote(dir+"/env") + `; exec sleep 10`, true},
+		{"exits", "echo 'Failed to create GL context' >&2; exit 3", false},
+	}
+	for _, tt := range tests {
+		bin := dir + "/tic80 " + tt.name
+		if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("bash", "-c", launchStep(bin).cmd).CombinedOutput()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err %v\n%s", tt.name, err, out)
+		}
+		if !tt.ok && !strings.Contains(string(out), "WARNING: "+bin+" exited with status 3") {
+			t.Errorf("%s: no warning in\n%s", tt.name, out)
+		}
+	}
+	data, err := os.ReadFile(dir + "/env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, driver, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if driver != "offscreen" || home == os.Getenv("HOME") {
+		t.Errorf("ran with HOME %q, SDL_VIDEODRIVER %q", home, driver)
+	}
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Errorf("throwaway HOME %s left behind", home)
+	}
+}
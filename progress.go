@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// --- PROGRESS PARSING ---
+
+var (
+	// git clone --progress, e.g. "Receiving objects:  42% (1234/2938)"
+	gitProgressRe = regexp.MustCompile(`(Counting|Compressing|Receiving|Resolving|Updating) (objects|deltas|files):\s+(\d+)%`)
+	// make's cmake-generated output, e.g. "[ 42%] Building C object ..."
+	makeProgressRe = regexp.MustCompile(`^\[\s*(\d+)%\]`)
+	// git submodule update, e.g. "Submodule path 'vendor/sdl2': checked out '1a2b...'"
+	submoduleRe = regexp.MustCompile(`^Submodule path '([^']+)': checked out`)
+)
+
+type stepProgressMsg struct {
+	step int
+	text string
+}
+
+// parseProgress returns a short status for a line of output, or "" if the
+// line carries no progress information.
+func parseProgress(line string) string {
+	if m := gitProgressRe.FindStringSubmatch(line); m != nil {
+		return fmt.Sprintf("%s %s: %s%%", m[1], m[2], m[3])
+	}
+	if m := makeProgressRe.FindStringSubmatch(line); m != nil {
+		return fmt.Sprintf("Building: %s%%", m[1])
+	}
+	if m := submoduleRe.FindStringSubmatch(line); m != nil {
+		return "Checked out " + m[1]
+	}
+	return ""
+}
+
+// STEP_LINE_MAX is where an unterminated line is cut, well under the
+// scanner's buffer limit, which would otherwise stop reading the step.
+const STEP_LINE_MAX = 64 * 1024
+
+// scanLinesOrCR works like bufio.ScanLines but also splits on a bare \r,
+// which git uses to redraw its progress in place. The terminator is left on
+// the token so the caller can tell a redraw from a finished line. \r and \n
+// never occur inside a UTF-8 sequence, and an overlong line is cut on a rune
+// boundary, so no character is split across tokens.
+func scanLinesOrCR(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i+1], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i+2], nil
+			}
+			return i + 1, data[:i+1], nil
+		}
+		if atEOF {
+			return i + 1, data[:i+1], nil
+		}
+		// Wait to see whether this \r is part of a \r\n.
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	if len(data) > STEP_LINE_MAX {
+		cut := STEP_LINE_MAX
+		for cut > STEP_LINE_MAX-utf8.UTFMax && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		return cut, data[:cut], nil
+	}
+	return 0, nil, nil
+}
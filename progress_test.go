@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// streamLines runs cmd as a step and collects the lines it sends.
+func streamLines(t *testing.T, cmd string) []string {
+	t.Helper()
+	out := make(chan tea.Msg)
+	go runStepStreamed(0, installStep{desc: "Testing...", cmd: cmd}, false, out)()
+	var lines []string
+	for msg := range out {
+		switch msg := msg.(type) {
+		case stepLineMsg:
+			lines = append(lines, msg.text)
+		case stepLogAndFinishMsg:
+			if msg.err != nil {
+				t.Fatalf("%s: %v", cmd, msg.err)
+			}
+			return lines
+		}
+	}
+	return lines
+}
+
+// TestScanLinesOrCRRunes cuts lines of multi-byte text longer than
+// STEP_LINE_MAX at every alignment, and checks no rune is split.
+func TestScanLinesOrCRRunes(t *testing.T) {
+	for _, r := range []string{"─", "漢", "🀄"} {
+		for pad := 0; pad < utf8.UTFMax; pad++ {
+			line := strings.Repeat("a", pad) + strings.Repeat(r, 3*STEP_LINE_MAX/len(r))
+			scanner := bufio.NewScanner(strings.NewReader(line + "\n"))
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			scanner.Split(scanLinesOrCR)
+			var tokens []string
+			for scanner.Scan() {
+				tokens = append(tokens, scanner.Text())
+			}
+			if len(tokens) < 2 {
+				t.Fatalf("%s pad %d: the line wasn't cut", r, pad)
+			}
+			for i, tok := range tokens {
+				if !utf8.ValidString(tok) {
+					t.Errorf("%s pad %d: token %d splits a rune", r, pad, i)
+				}
+				if !strings.HasSuffix(tok, "\n") && len(tok) > STEP_LINE_MAX {
+					t.Errorf("%s pad %d: token %d cut at %d bytes", r, pad, i, len(tok))
+				}
+			}
+			if got := strings.Join(tokens, ""); got != line+"\n" {
+				t.Errorf("%s pad %d: the tokens don't add up to the line", r, pad)
+			}
+		}
+	}
+}
+
+// TestStepLinesInvalidUTF8 checks bytes that aren't UTF-8 reach the model as
+// U+FFFD, also when one sits on the STEP_LINE_MAX cut, and that valid text
+// on either side of it comes through whole.
+func TestStepLinesInvalidUTF8(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("a", STEP_LINE_MAX-1) + "\xe2\x94" + strings.Repeat("─", 10)
+	files := map[string]string{
+		"latin1":   "caf\xe9 ok\n",
+		"boundary": long + "\n",
+		"cjk":      strings.Repeat("漢字", STEP_LINE_MAX/3) + "\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lines := streamLines(t, "cat "+shellQuote(path))
+		joined := strings.Join(lines, "")
+		for i, line := range lines {
+			if !utf8.ValidString(line) {
+				t.Errorf("%s: line %d isn't valid UTF-8", name, i)
+			}
+		}
+		if want := strings.ToValidUTF8(strings.TrimSuffix(text, "\n"), "\uFFFD"); joined != want {
+			t.Errorf("%s: got %d bytes, want %d", name, len(joined), len(want))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+var foregroundRe = regexp.MustCompile(`\x1b\[(?:[\d;]*;)?38;2;(\d+);(\d+);(\d+)`)
+
+// foregrounds lists the truecolor foreground of each colored run in s.
+func foregrounds(s string) [][3]int {
+	var colors [][3]int
+	for _, m := range foregroundRe.FindAllStringSubmatch(s, -1) {
+		var rgb [3]int
+		for i, c := range m[1:] {
+			rgb[i], _ = strconv.Atoi(c)
+		}
+		colors = append(colors, rgb)
+	}
+	return colors
+}
+
+// sameColor compares rgb with a #rrggbb, allowing for the rounding of
+// lipgloss's color conversion.
+func sameColor(rgb [3]int, hex string) bool {
+	var want [3]int
+	fmt.Sscanf(hex, "#%02x%02x%02x", &want[0], &want[1], &want[2])
+	for i := range rgb {
+		if d := rgb[i] - want[i]; d < -1 || d > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRenderRainbow(t *testing.T) {
+	old := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	t.Cleanup(func() { lipgloss.SetColorProfile(old) })
+
+	n := len(RainbowColors)
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", ""},
+		{"ascii", "TIC-80"},
+		{"box drawing", "╭─╮│╰╯"},
+		{"cjk and emoji", "漢字🀄é"},
+		{"wraps past the palette", strings.Repeat("x", 2*n+1)},
+	}
+	for _, tt := range tests {
+		got := renderRainbow(tt.text)
+		if plain := ansi.Strip(got); plain != tt.text {
+			t.Errorf("%s: text came out as %q", tt.name, plain)
+		}
+		colors := foregrounds(got)
+		runes := []rune(tt.text)
+		if len(colors) != len(runes) {
+			t.Errorf("%s: %d colored runs for %d runes", tt.name, len(colors), len(runes))
+			continue
+		}
+		for i, c := range colors {
+			if want := RainbowColors[i%n].TrueColor; !sameColor(c, want) {
+				t.Errorf("%s: rune %d (%q) is %v, want %s", tt.name, i, runes[i], c, want)
+			}
+		}
+	}
+}
+
+func TestRenderRainbowEmptyPalette(t *testing.T) {
+	old, oldProfile := RainbowColors, lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	RainbowColors = nil
+	t.Cleanup(func() {
+		RainbowColors = old
+		lipgloss.SetColorProfile(oldProfile)
+	})
+	got := renderRainbow("漢字 ok")
+	if plain := ansi.Strip(got); plain != "漢字 ok" {
+		t.Errorf("text came out as %q", plain)
+	}
+	if colors := foregrounds(got); len(colors) != 1 || !sameColor(colors[0], ColorWhite.TrueColor) {
+		t.Errorf("foregrounds %v, want %s throughout", colors, ColorWhite.TrueColor)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- GIT REF ---
+
+const TIC80_REPO = "https://github.com/nesbox/TIC-80.git"
+
+const REF_CHECK_TIMEOUT = 15 * time.Second
+
+// remoteRefs lists the branch and tag names of repo.
+func remoteRefs(repo string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), REF_CHECK_TIMEOUT)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "ls-remote", "--tags", "--heads", repo).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list refs of %s: %w", repo, err)
+	}
+	var refs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		_, name, ok := strings.Cut(line, "\t")
+		if !ok || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "refs/heads/")
+		name = strings.TrimPrefix(name, "refs/tags/")
+		refs = append(refs, name)
+	}
+	return refs, nil
+}
+
+// checkRef fails fast when ref is neither a branch nor a tag of repo,
+// instead of letting the clone fail after the fact.
+func checkRef(repo, ref string) error {
+	refs, err := remoteRefs(repo)
+	if err != nil {
+		return err
+	}
+	for _, r := range refs {
+		if r == ref {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("ref '%s' not found", ref)
+	if close := closeMatches(ref, refs, 5); len(close) > 0 {
+		msg += ", did you mean: " + strings.Join(close, ", ")
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// closeMatches returns up to n candidates nearest to s by edit distance,
+// ignoring anything too far off to be a typo.
+func closeMatches(s string, candidates []string, n int) []string {
+	type match struct {
+		name string
+		dist int
+	}
+	var matches []match
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(s), strings.ToLower(c))
+		if d <= max(2, len(s)/3) || strings.Contains(c, s) {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].dist < matches[j].dist })
+	var out []string
+	for i := 0; i < len(matches) && i < n; i++ {
+		out = append(out, matches[i].name)
+	}
+	return out
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --- RUN REPORT ---
+
+var REPORT_FILE = filepath.Join(STATE_DIR, "last-report.json")
+
+// Lines of each step's output kept in the report, from the start and the end.
+const (
+	DEFAULT_REPORT_HEAD = 50
+	DEFAULT_REPORT_TAIL = 200
+)
+
+// stepOutput keeps the first and last lines of a step's output, so a compile
+// that prints megabytes doesn't end up whole in the report. The log file has
+// everything.
+type stepOutput struct {
+	head    []string
+	headMax int
+	tail    scrollback
+	omitted int // lines beyond the head when there's no tail to keep them
+}
+
+// newStepOutputs makes one stepOutput per step.
+func newStepOutputs(n int, opts options) []*stepOutput {
+	outputs := make([]*stepOutput, n)
+	for i := range outputs {
+		outputs[i] = &stepOutput{headMax: opts.reportHead, tail: scrollback{max: opts.reportTail}}
+	}
+	return outputs
+}
+
+func (o *stepOutput) add(line string) {
+	switch {
+	case len(o.head) < o.headMax:
+		o.head = append(o.head, line)
+	case o.tail.max > 0:
+		o.tail.push(line)
+	default:
+		o.omitted++
+	}
+}
+
+func (o *stepOutput) lines() []string {
+	lines := append([]string(nil), o.head...)
+	if n := o.omitted + o.tail.dropped; n > 0 {
+		lines = append(lines, fmt.Sprintf("... %d lines omitted ...", n))
+	}
+	return append(lines, o.tail.ordered()...)
+}
+
+type stepReport struct {
+	Desc   string   `json:"desc"`
+	Cmd    string   `json:"cmd"`
+	Status string   `json:"status"` // ok, failed, warning (non-fatal failure), aborted or skipped
+	Output []string `json:"output,omitempty"`
+}
+
+// runReport is a machine-readable record of the last run, written to
+// REPORT_FILE alongside the history.
+type runReport struct {
+	Op       string            `json:"op"`
+	Start    time.Time         `json:"start"`
+	Date     string            `json:"date"` // Start as --time-format writes it
+	Label    string            `json:"label,omitempty"`
+	Duration float64           `json:"duration_s"`
+	Success  bool              `json:"success"`
+	Error    string            `json:"error,omitempty"`
+	Settings map[string]string `json:"settings"`
+	Steps    []stepReport      `json:"steps"`
+	// From the Recording CMake cache... step of this run, if it had one.
+	CMakeCache map[string]string `json:"cmake_cache,omitempty"`
+}
+
+// writeReport records the run; failed is the index of the step that failed,
+// or len(steps) if none did, and marks overrides the status of steps that
+// finished otherwise than ok. outputs holds each step's windowed output.
+// Like history, it's best effort.
+func writeReport(a action, opts options, steps []installStep, outputs []*stepOutput, failed int, marks map[int]string, start, end time.Time, runErr error) {
+	report := runReport{
+		Op:         operationNames[a],
+		Start:      start,
+		Date:       formatTime(start, opts.timeFormat, true),
+		Label:      opts.label,
+		Duration:   end.Sub(start).Round(time.Second).Seconds(),
+		Success:    runErr == nil,
+		Settings:   map[string]string{},
+		CMakeCache: cmakeCacheVars(start),
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	for _, row := range summaryRows(opts) {
+		report.Settings[row.label] = row.value
+	}
+	for i, step := range steps {
+		status := "ok"
+		if i == failed && runErr != nil {
+			status = "failed"
+		} else if i >= failed {
+			status = "skipped"
+		} else if mark, ok := marks[i]; ok {
+			status = mark
+		}
+		report.Steps = append(report.Steps, stepReport{Desc: step.desc, Cmd: step.cmd, Status: status, Output: outputs[i].lines()})
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(STATE_DIR, 0755) != nil {
+		return
+	}
+	os.WriteFile(REPORT_FILE, append(data, '\n'), 0644)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- RESET EVERYTHING ---
+
+// resetTargets is everything the tool may have created: the install (from
+// the manifest when there is one), the service unit, demo carts, the build
+// tree and cache, ccache's store, and its logs, state and config. ccache
+// only runs with --cache-dir, with a store of the tool's own, so no other
+// build's cache goes with it.
+func resetTargets(opts options) []string {
+	paths := removalPaths(opts)
+	paths = append(paths, installedFiles(opts)...)
+	paths = append(paths, BUILD_DIR, CACHE_HOME, LOG_FILE, STATE_DIR, CONFIG_DIR)
+	if CCACHE_DIR != "" {
+		paths = append(paths, CCACHE_DIR)
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// existingResetTargets is what a reset would actually delete. It stats every
+// target and reads the manifest, so the confirm screen works it out once,
+// when it opens.
+func existingResetTargets(opts options) []string {
+	var present []string
+	for _, path := range resetTargets(opts) {
+		if fileExists(path) {
+			present = append(present, path)
+		}
+	}
+	return present
+}
+
+type resetDoneMsg struct{ err error }
+
+// resetEverything disables the service before its unit goes, then deletes
+// targets, carrying on past failures so one stuck file doesn't leave the
+// rest behind.
+func resetEverything(targets []string) tea.Cmd {
+	return func() tea.Msg {
+		if out, err := exec.Command("bash", "-c", serviceRemoveStep().cmd).CombinedOutput(); err != nil {
+			return resetDoneMsg{fmt.Errorf("removing service: %v: %s", err, strings.TrimSpace(string(out)))}
+		}
+		var errs []error
+		for _, path := range targets {
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return resetDoneMsg{errors.Join(errs...)}
+	}
+}
+
+// renderResetConfirm lists what will go before either confirmation, and
+// while it goes at stage 2.
+func renderResetConfirm(present []string, stage int) string {
+	var s strings.Builder
+	s.WriteString(" " + styleError.Render("Reset Everything") + "\n\n")
+	if len(present) == 0 {
+		s.WriteString(" " + styleLog.Render("Nothing to delete, the tool has left nothing behind.") + "\n")
+		s.WriteString("\n " + styleLog.Render("Esc to go back"))
+		return s.String()
+	}
+	s.WriteString(" " + styleLog.Render("Will delete:") + "\n")
+	for _, path := range present {
+		s.WriteString("   " + stylePresent.Render(path) + "\n")
+	}
+	switch stage {
+	case 0:
+		s.WriteString("\n " + styleLog.Render("Press Y to continue, Esc to cancel"))
+	case 1:
+		s.WriteString("\n " + styleError.Render("This can't be undone. Press Y again to delete all of the above, Esc to cancel"))
+	default:
+		s.WriteString("\n " + styleLog.Render("Deleting..."))
+	}
+	return s.String()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestResetTargetsCcache(t *testing.T) {
+	old := CCACHE_DIR
+	t.Cleanup(func() { CCACHE_DIR = old })
+	CCACHE_DIR = "/var/cache/tic80-test/ccache"
+	if !slices.Contains(resetTargets(defaultOptions()), CCACHE_DIR) {
+		t.Errorf("CCACHE_DIR %s not in the reset targets", CCACHE_DIR)
+	}
+}
+
+// TestResetRunsAsCmd checks the second Y hands the deleting to a Cmd, with
+// the list taken when the screen opened, and holds the lock until it's done.
+func TestResetRunsAsCmd(t *testing.T) {
+	useTempState(t)
+	useTempLock(t)
+	target := filepath.Join(t.TempDir(), "build")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(defaultOptions())
+	m.state, m.resetList = stateResetConfirm, []string{target}
+	y := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")}
+
+	next, _ := m.Update(y)
+	next, cmd := next.(model).Update(y)
+	m = next.(model)
+	if cmd == nil || m.resetStage != 2 {
+		t.Fatalf("no Cmd for the reset, stage %d", m.resetStage)
+	}
+	if !fileExists(target) {
+		t.Fatal("Update deleted the targets itself")
+	}
+	if instanceLock == nil {
+		t.Error("reset running without the lock")
+	}
+	next, _ = m.Update(resetDoneMsg{})
+	m = next.(model)
+	if m.state != stateDone || m.err != nil || instanceLock != nil {
+		t.Errorf("after the reset: state %v, err %v, lock held %v", m.state, m.err, instanceLock != nil)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "fmt"
+
+// --- CLONE RESUMPTION ---
+
+// cloneMarker exists in the build dir while a clone and its submodules are
+// still being fetched, so the next run can tell an interrupted clone from
+// an old build tree.
+func cloneMarker() string {
+	return BUILD_DIR + "/.clone-incomplete"
+}
+
+// cleanBuildDir removes the previous build tree, except a checkout an
+// interrupted clone left behind, which the clone step then resumes.
+func cleanBuildDir() string {
+	buildDir, src, marker := shellQuote(BUILD_DIR), shellQuote(SRC_DIR), shellQuote(cloneMarker())
+	return fmt.Sprintf(`if [ -f %s ] && [ -d %s/.git ]; then echo "Keeping the interrupted clone in "%s" to resume it."; `+
+		`find %s -mindepth 1 -maxdepth 1 ! -path %s ! -path %s -exec rm -rf {} +; else rm -rf %s; fi`,
+		marker, src, src, buildDir, src, marker, buildDir)
+}
+
+// resumableClone runs clone, or resumes the checkout an interrupted one left:
+// the history fetched so far is kept and only the rest, plus any missing
+// submodules unless a later step fetches those, is downloaded. If resuming
+// fails it falls back to a fresh clone.
+func resumableClone(clone, fetch string, withSubmodules bool) string {
+	src, marker := shellQuote(SRC_DIR), shellQuote(cloneMarker())
+	resume := fmt.Sprintf("git -C %s fetch --progress --tags origin %s && git -C %s reset --hard FETCH_HEAD", src, fetch, src)
+	if withSubmodules {
+		resume += fmt.Sprintf(" && git -C %s submodule update --init --recursive --progress", src)
+	}
+	fresh := fmt.Sprintf("rm -rf %s && touch %s && %s", src, marker, clone)
+	cmd := fmt.Sprintf(`if [ -f %s ] && [ -d %s/.git ]; then echo "Resuming the interrupted clone..."; `+
+		`{ %s; } || { echo "Resuming failed, cloning again." >&2; %s; }; else %s; fi`,
+		marker, src, resume, fresh, fresh)
+	if withSubmodules {
+		cmd += " && rm -f " + marker
+	}
+	return cmd
+}
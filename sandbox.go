@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// --- SANDBOX ---
+
+// shellQuote wraps s in single quotes for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sandboxAvailable(opts options) bool {
+	if opts.sandbox != "bwrap" {
+		return false
+	}
+	_, err := exec.LookPath("bwrap")
+	return err == nil
+}
+
+// sandboxed runs a build step inside bubblewrap: the whole filesystem is
+// read-only except the build dir (and --source-dir and the vendor cache), and there is no
+// network. Install steps are never wrapped since they have to write to the
+// prefix.
+func sandboxed(step installStep, opts options) installStep {
+	if !sandboxAvailable(opts) {
+		return step
+	}
+	binds := fmt.Sprintf("--bind %s %s", shellQuote(BUILD_DIR), shellQuote(BUILD_DIR))
+	if opts.sourceDir != "" {
+		binds += fmt.Sprintf(" --bind %s %s", shellQuote(opts.sourceDir), shellQuote(opts.sourceDir))
+	}
+	if opts.vendorCache {
+		binds += fmt.Sprintf(" --bind %s %s", shellQuote(VENDOR_CACHE_DIR), shellQuote(VENDOR_CACHE_DIR))
+	}
+	step.cmd = fmt.Sprintf("bwrap --ro-bind / / %s --dev /dev --proc /proc --tmpfs /tmp --unshare-all --die-with-parent bash -c %s",
+		binds, shellQuote(step.cmd))
+	return step
+}
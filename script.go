@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- SCRIPT EXPORT ---
+
+// operationNames maps the step-producing actions to the names used by flags
+// and exported file names.
+var operationNames = map[action]string{
+	actionInstall:          "install",
+	actionUpgrade:          "upgrade",
+	actionUninstall:        "uninstall",
+	actionCleanReinstall:   "reinstall",
+	actionDeps:             "deps",
+	actionInstallExisting:  "install-existing",
+	actionRecompileVerbose: "recompile-verbose",
+}
+
+func parseOperation(name string) (action, bool) {
+	for a, n := range operationNames {
+		if n == name {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// renderScript turns the resolved steps into a standalone bash script. Each
+// step runs in its own subshell so a `cd` behaves the same as under bash -c.
+func renderScript(a action, opts options) string {
+	var s strings.Builder
+	s.WriteString("#!/usr/bin/env bash\n")
+	s.WriteString(fmt.Sprintf("# Generated by tic80-manager (%s). Run as root.\n", operationNames[a]))
+	s.WriteString("set -euo pipefail\n")
+	for _, step := range getSteps(a, opts) {
+		if step.nonFatal {
+			s.WriteString(fmt.Sprintf("\n# %s (non-fatal)\n(\n%s\n) || echo %s >&2\n", step.desc, step.cmd, shellQuote("WARNING: "+step.desc+" failed, continuing")))
+			continue
+		}
+		s.WriteString(fmt.Sprintf("\n# %s\n(\n%s\n)\n", step.desc, step.cmd))
+	}
+	return s.String()
+}
+
+// writeScript only writes the file; nothing in it is executed.
+func writeScript(path string, a action, opts options) error {
+	return os.WriteFile(path, []byte(renderScript(a, opts)), 0755)
+}
+
+type scriptWrittenMsg struct {
+	path string
+	err  error
+}
+
+// exportScript is Export Script in the menu, writing off the Update loop.
+func exportScript(path string, a action, opts options) tea.Cmd {
+	return func() tea.Msg {
+		return scriptWrittenMsg{path: path, err: writeScript(path, a, opts)}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestExportScriptRunsAsCmd checks picking an operation to export leaves the
+// write to a Cmd and says where the script went once it's back.
+func TestExportScriptRunsAsCmd(t *testing.T) {
+	t.Chdir(t.TempDir())
+	m := initialModel(defaultOptions())
+	m.state, m.pickFor, m.choices, m.cursor = stateExportPick, actionExportScript, exportMenu, 0
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	path := "tic80-" + operationNames[exportMenu[0].action] + ".sh"
+	if cmd == nil || m.state != stateExportPick || fileExists(path) {
+		t.Fatalf("Update wrote the script itself: state %v", m.state)
+	}
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	if m.state != stateDone || m.err != nil || !strings.Contains(m.logMsg, path) {
+		t.Fatalf("state %v, err %v, message %q", m.state, m.err, m.logMsg)
+	}
+	if !fileExists(path) {
+		t.Error("no script written")
+	}
+}
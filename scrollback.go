@@ -0,0 +1,41 @@
+package main
+
+// --- SCROLLBACK ---
+
+const DEFAULT_SCROLLBACK = 5000
+
+// scrollback keeps the last max lines of output in a ring, so a build that
+// spews megabytes doesn't slow the viewport down. The full output is still
+// in the log file. A max of 0 keeps everything.
+type scrollback struct {
+	lines   []string
+	start   int // index of the oldest line once the ring is full
+	max     int
+	dropped int
+}
+
+func (s *scrollback) push(line string) {
+	if s.max <= 0 || len(s.lines) < s.max {
+		s.lines = append(s.lines, line)
+		return
+	}
+	s.lines[s.start] = line
+	s.start = (s.start + 1) % s.max
+	s.dropped++
+}
+
+// ordered returns the kept lines oldest first.
+func (s *scrollback) ordered() []string {
+	out := make([]string, 0, len(s.lines))
+	out = append(out, s.lines[s.start:]...)
+	return append(out, s.lines[:s.start]...)
+}
+
+func (s *scrollback) reset() {
+	s.lines, s.start, s.dropped = nil, 0, 0
+}
+
+// total counts every line pushed, including dropped ones.
+func (s *scrollback) total() int {
+	return len(s.lines) + s.dropped
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- LOG PANE SCROLLBAR ---
+
+var (
+	styleScrollTrack = lipgloss.NewStyle().Foreground(ColorPurple).Background(ColorVoid)
+	styleScrollThumb = lipgloss.NewStyle().Foreground(ColorGrey).Background(ColorVoid)
+)
+
+// renderTermBox draws the viewport in the terminal box with a scrollbar in
+// its right padding and the position in the top border, e.g.
+// "╭─ line 4200/50000 (84%) ───╮".
+func renderTermBox(vp viewport.Model, border lipgloss.CompleteColor) string {
+	vp.Style = styleTermBox.BorderForeground(border)
+	lines := strings.Split(vp.View(), "\n")
+	total, visible := vp.TotalLineCount(), vp.VisibleLineCount()
+	if len(lines) < 3 || total <= visible {
+		return strings.Join(lines, "\n")
+	}
+
+	edge := lipgloss.NewStyle().Foreground(border).Background(ColorVoid)
+	width := ansi.StringWidth(lines[0])
+	title := fmt.Sprintf(" line %d/%d (%d%%) ", vp.YOffset+visible, total, int(math.Round(vp.ScrollPercent()*100)))
+	if fill := width - 3 - ansi.StringWidth(title); fill >= 0 {
+		lines[0] = edge.Render("╭─"+title+strings.Repeat("─", fill)) + edge.Render("╮")
+	}
+
+	rows := lines[1 : len(lines)-1]
+	thumb := max(1, len(rows)*visible/total)
+	top := int(math.Round(float64(len(rows)-thumb) * vp.ScrollPercent()))
+	for i, row := range rows {
+		w := ansi.StringWidth(row)
+		bar := styleScrollTrack.Render("░")
+		if i >= top && i < top+thumb {
+			bar = styleScrollThumb.Render("█")
+		}
+		// Swap the last padding cell before the right border for the bar.
+		rows[i] = ansi.Truncate(row, w-2, "") + bar + ansi.Cut(row, w-1, w)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- SELINUX ---
+
+// SELinuxError is an install step that failed while SELinux was denying
+// access; its AVC messages don't read like ordinary permission errors.
+type SELinuxError struct {
+	*StepError
+	Denials []string
+}
+
+func (e *SELinuxError) Error() string {
+	return fmt.Sprintf("%s: SELinux denied it (%s). Relabel the install prefix with `restorecon -Rv`, or see `ausearch -m AVC -ts recent`",
+		e.StepError.Error(), e.Denials[len(e.Denials)-1])
+}
+
+func (e *SELinuxError) Unwrap() error { return e.StepError }
+
+// probe runs a diagnostic command, giving up quickly if it hangs.
+func probe(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	out, _ := exec.CommandContext(ctx, name, args...).Output()
+	return string(out)
+}
+
+func selinuxEnforcing() bool {
+	return strings.TrimSpace(probe("getenforce")) == "Enforcing"
+}
+
+// dmesgTimeRe is the [seconds since boot] a kernel log line starts with.
+var dmesgTimeRe = regexp.MustCompile(`^\[\s*(\d+\.\d+)\]`)
+
+// recentDenials are the AVC denials since start, from the audit log, or the
+// kernel log where ausearch isn't installed. ausearch only goes back by
+// minutes; the kernel log has everything since boot, so it is cut at start.
+func recentDenials(start time.Time) []string {
+	if _, err := exec.LookPath("ausearch"); err == nil {
+		return denialLines(probe("ausearch", "-m", "AVC", "-ts", "recent"))
+	}
+	up, ok := uptime()
+	if !ok {
+		return nil
+	}
+	return denialLines(kernelLogSince(probe("dmesg"), up-time.Since(start)))
+}
+
+// uptime is how long ago the system booted, the clock dmesg stamps with.
+func uptime() (time.Duration, bool) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
+
+// kernelLogSince keeps the dmesg lines stamped at since or later, a second
+// early for the rounding, and drops those without a stamp.
+func kernelLogSince(out string, since time.Duration) string {
+	var kept []string
+	for _, line := range strings.Split(out, "\n") {
+		m := dmesgTimeRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		secs, err := strconv.ParseFloat(m[1], 64)
+		if err == nil && time.Duration(secs*float64(time.Second)) >= since-time.Second {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func denialLines(out string) []string {
+	var denials []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "avc:") && strings.Contains(line, "denied") {
+			if _, msg, ok := strings.Cut(line, "avc:"); ok {
+				line = "avc:" + msg
+			}
+			denials = append(denials, strings.TrimSpace(line))
+		}
+	}
+	return denials
+}
+
+// selinuxWarning flags enforcing mode at preflight when the prefix is outside
+// the usual install locations, whose labels may not allow the install.
+func selinuxWarning(opts options) string {
+	for _, dir := range []string{"/usr", "/opt"} {
+		if opts.prefix == dir || strings.HasPrefix(opts.prefix, dir+"/") {
+			return ""
+		}
+	}
+	if !selinuxEnforcing() {
+		return ""
+	}
+	return fmt.Sprintf("SELinux is enforcing and %s is outside /usr and /opt; if the install is denied, relabel it with `restorecon -Rv %s` or use --prefix /usr/local", opts.prefix, opts.prefix)
+}
+
+// installError explains a failed install by SELinux when it is enforcing and
+// denied something while the step ran.
+func installError(se *StepError) error {
+	if !selinuxEnforcing() {
+		return se
+	}
+	if denials := recentDenials(se.Start); len(denials) > 0 {
+		return &SELinuxError{StepError: se, Denials: denials}
+	}
+	return se
+}
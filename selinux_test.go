@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKernelLogSince(t *testing.T) {
+	out := `[   12.500000] audit: type=1400 avc:  denied  { write } for pid=1 comm="install" name="old" scontext=a tcontext=b tclass=dir
+[ 1000.000000] eth0: link up
+[ 1234.250000] audit: type=1400 avc:  denied  { create } for pid=2 comm="install" name="tic80" scontext=a tcontext=b tclass=file
+no stamp avc: denied`
+	denials := denialLines(kernelLogSince(out, 1234*time.Second))
+	if len(denials) != 1 {
+		t.Fatalf("got %d denials, want only the one after the step started: %q", len(denials), denials)
+	}
+	if want := `avc:  denied  { create } for pid=2 comm="install" name="tic80" scontext=a tcontext=b tclass=file`; denials[0] != want {
+		t.Errorf("denial = %q, want %q", denials[0], want)
+	}
+	if got := denialLines(kernelLogSince(out, 2000*time.Second)); len(got) != 0 {
+		t.Errorf("denials from before the step: %q", got)
+	}
+}
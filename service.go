@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- SYSTEMD SERVICE ---
+
+const SERVICE_NAME = "tic80.service"
+
+// serviceUnitPath returns where the unit file lives for a scope. User units go
+// in the global user directory so every account picks them up, since we run
+// as root and can't write into the invoking user's home reliably.
+func serviceUnitPath(scope string) string {
+	if scope == "user" {
+		return "/etc/systemd/user/" + SERVICE_NAME
+	}
+	return "/etc/systemd/system/" + SERVICE_NAME
+}
+
+func serviceUnit(opts options) string {
+	wantedBy := "multi-user.target"
+	if opts.serviceScope == "user" {
+		wantedBy = "default.target"
+	}
+	execStart := strings.TrimSpace(systemdQuote(binPath(opts.prefix)) + " " + opts.serviceArgs)
+	return fmt.Sprintf(`[Unit]
+Description=TIC-80 Pro
+After=network.target
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+
+[Install]
+WantedBy=%s
+`, execStart, wantedBy)
+}
+
+// systemdQuote makes path one word of a unit's command line, which systemd
+// splits on spaces and where it expands $ and % itself.
+func systemdQuote(path string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%")
+	return `"` + r.Replace(path) + `"`
+}
+
+func serviceSystemctl(scope string) string {
+	if scope == "user" {
+		return "systemctl --global"
+	}
+	return "systemctl daemon-reload && systemctl"
+}
+
+func serviceInstallSteps(opts options) []installStep {
+	path := serviceUnitPath(opts.serviceScope)
+	return []installStep{
+		{desc: "Writing systemd unit...", cmd: fmt.Sprintf("cat > %s <<'EOF'\n%sEOF", path, serviceUnit(opts))},
+		{desc: "Enabling service...", cmd: fmt.Sprintf("%s enable %s", serviceSystemctl(opts.serviceScope), SERVICE_NAME)},
+	}
+}
+
+// serviceRemoveStep cleans up a unit from either scope, so uninstall works
+// regardless of the flags the install was run with.
+func serviceRemoveStep() installStep {
+	var cmds []string
+	for _, scope := range []string{"system", "user"} {
+		path := serviceUnitPath(scope)
+		cmds = append(cmds, fmt.Sprintf("if [ -f %s ]; then %s disable %s; rm -f %s; fi",
+			path, serviceSystemctl(scope), SERVICE_NAME, path))
+	}
+	return installStep{desc: "Removing Service...", cmd: strings.Join(cmds, "; ")}
+}
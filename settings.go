@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// --- SETTINGS ---
+
+// A setting is one line of the settings screen; Enter calls next to move it
+// to its following value, or opens another screen to pick it on. keep copies
+// the value across, see keepEdits.
+type setting struct {
+	label string
+	value func(options) string
+	next  func(*options)
+	keep  func(to *options, from options)
+	open  state // stateMenu for none
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func toggle(label string, field func(*options) *bool) setting {
+	return setting{
+		label: label,
+		value: func(o options) string { return onOff(*field(&o)) },
+		next:  func(o *options) { *field(o) = !*field(o) },
+		keep:  func(to *options, from options) { *field(to) = *field(&from) },
+	}
+}
+
+// cycle steps through a fixed list of values, starting over after the last;
+// unset is shown for the empty value.
+func cycle(label, unset string, field func(*options) *string, values ...string) setting {
+	return setting{
+		label: label,
+		value: func(o options) string {
+			if v := *field(&o); v != "" {
+				return v
+			}
+			return unset
+		},
+		next: func(o *options) {
+			cur := *field(o)
+			for i, v := range values {
+				if v == cur {
+					*field(o) = values[(i+1)%len(values)]
+					return
+				}
+			}
+			*field(o) = values[0]
+		},
+		keep: func(to *options, from options) { *field(to) = *field(&from) },
+	}
+}
+
+// cmakeOption switches one of the feature flags cmakeArgs turns on, by
+// adding or removing an override in --cmake-flag.
+func cmakeOption(label, name string) setting {
+	off := "-D" + name + "=Off"
+	has := func(o options) int {
+		for i, f := range o.cmakeFlags {
+			if f == off {
+				return i
+			}
+		}
+		return -1
+	}
+	return setting{
+		label: label,
+		value: func(o options) string { return onOff(has(o) < 0) },
+		next: func(o *options) {
+			if i := has(*o); i >= 0 {
+				o.cmakeFlags = append(append(stringList(nil), o.cmakeFlags[:i]...), o.cmakeFlags[i+1:]...)
+			} else {
+				o.cmakeFlags = append(append(stringList(nil), o.cmakeFlags...), off)
+			}
+		},
+		keep: func(to *options, from options) {
+			if i := has(*to); i >= 0 {
+				to.cmakeFlags = append(append(stringList(nil), to.cmakeFlags[:i]...), to.cmakeFlags[i+1:]...)
+			}
+			if has(from) >= 0 {
+				to.cmakeFlags = append(append(stringList(nil), to.cmakeFlags...), off)
+			}
+		},
+	}
+}
+
+var settings = []setting{
+	prefixPicker("Install location (prefix)"),
+	cmakeOption("All languages (BUILD_WITH_ALL)", "BUILD_WITH_ALL"),
+	cycle("Renderer", "sdlgpu", func(o *options) *string { return &o.renderer }, "sdlgpu", "sdl"),
+	cmakeOption("Static link (BUILD_STATIC)", "BUILD_STATIC"),
+	toggle("Patch SDL2", func(o *options) *bool { return &o.patchSDL }),
+	toggle("Reproducible", func(o *options) *bool { return &o.reproducible }),
+	toggle("Verify signature", func(o *options) *bool { return &o.verifySignature }),
+	cycle("Jobs", "nproc", func(o *options) *string { return &o.jobs }, "", "auto"),
+	toggle("Performance governor", func(o *options) *bool { return &o.performance }),
+	cycle("Sandbox", "off", func(o *options) *string { return &o.sandbox }, "", "bwrap"),
+	toggle("Cache", func(o *options) *bool { return &o.cache }),
+	toggle("Vendor cache", func(o *options) *bool { return &o.vendorCache }),
+	toggle("Build as root", func(o *options) *bool { return &o.allowRoot }),
+	toggle("Keep build", func(o *options) *bool { return &o.keepBuild }),
+	toggle("Install demo carts", func(o *options) *bool { return &o.installDemos }),
+	toggle("Register .tic files", func(o *options) *bool { return &o.registerMime }),
+	toggle("Install service", func(o *options) *bool { return &o.installService }),
+	toggle("Timestamps", func(o *options) *bool { return &o.timestamps }),
+	cycle("Time format", "iso", func(o *options) *string { return &o.timeFormat }, "iso", "locale"),
+	toggle("Open log on error", func(o *options) *bool { return &o.autoLog }),
+}
+
+// keepEdits copies what was changed on the settings screen from m.opts into
+// opts, so picking a preset doesn't undo it.
+func (m model) keepEdits(opts *options) {
+	for i := range m.edited {
+		settings[i].keep(opts, m.opts)
+	}
+}
+
+// settingsPreview is every build command the current settings produce, so the
+// effect of a toggle shows straight away.
+func settingsPreview(opts options) []string {
+	var cmds []string
+	for _, step := range getSteps(actionInstall, opts) {
+		switch step.desc {
+		case "Cloning Repository...", "Fetching Submodules...", "Updating Repository...", "Patching SDL2...",
+			"Building vendored dependencies...", "Configuring CMake (Forcing Pro)...", "Compiling...", "Installing...":
+			cmds = append(cmds, step.cmd)
+		}
+	}
+	return cmds
+}
+
+func renderSettings(opts options, cursor, width int) string {
+	var s strings.Builder
+	for i, set := range settings {
+		line := set.label + strings.Repeat(" ", max(1, 34-len(set.label))) + set.value(opts)
+		if i == cursor {
+			cursor := lipgloss.NewStyle().Foreground(ColorRed).Background(ColorVoid).Render(">█ ")
+			s.WriteString(" " + cursor + styleSelected.Render(line) + "\n")
+		} else {
+			s.WriteString("    " + styleNormal.Render(line) + "\n")
+		}
+	}
+	s.WriteString("\n " + styleLog.Render("Enter changes the setting, Esc to go back") + "\n\n")
+	wrap := styleTermText.PaddingLeft(1).Width(max(20, width-2))
+	for _, cmd := range settingsPreview(opts) {
+		s.WriteString(wrap.Render("$ "+cmd) + "\n")
+	}
+	return s.String()
+}
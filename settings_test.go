@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestSettingsKeptOverPreset changes settings, some of them ones the preset
+// sets too, and checks picking a preset afterwards keeps every change.
+func TestSettingsKeptOverPreset(t *testing.T) {
+	m := initialModel(defaultOptions())
+	row := func(label string) int {
+		for i, set := range settings {
+			if set.label == label {
+				return i
+			}
+		}
+		t.Fatalf("no setting %q", label)
+		return 0
+	}
+	enter := func(m model) model {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		return next.(model)
+	}
+	m.state = stateSettings
+	for _, label := range []string{"Renderer", "Static link (BUILD_STATIC)", "Reproducible"} {
+		m.setCursor = row(label)
+		m = enter(m)
+	}
+	m.prefixBack = stateSettings
+	m.setCursor = row("Install location (prefix)")
+	m.pickPrefix(t.TempDir())
+	prefix := m.opts.prefix
+
+	m.state = statePresetPick
+	m.choices = []menuItem{{"static-minimal", actionPresets}}
+	m.cursor = 0
+	m = enter(m)
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if m.opts.preset != "static-minimal" || m.opts.renderer != "sdl" || !m.opts.reproducible || m.opts.prefix != prefix {
+		t.Errorf("preset %q, renderer %q, reproducible %v, prefix %q", m.opts.preset, m.opts.renderer, m.opts.reproducible, m.opts.prefix)
+	}
+	want := append(builtinPresets["static-minimal"].values["cmake-flag"].([]any), "-DBUILD_STATIC=Off")
+	var got []any
+	for _, f := range m.opts.cmakeFlags {
+		got = append(got, f)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("cmake flags %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+)
+
+// --- FIRST-RUN SETUP ---
+
+// firstRun is true until the tool has written its config or any state.
+func firstRun() bool {
+	return !fileExists(CONFIG_DIR) && !fileExists(STATE_DIR)
+}
+
+// detectedPreset picks the preset for the running distro, a definition from
+// DISTROS_DIR before a built-in one, falling back to the Fedora defaults the
+// tool was written for.
+func detectedPreset() string {
+	if d, ok := detectedDistro(); ok {
+		return d.name
+	}
+	ids := strings.Fields(osReleaseField("ID") + " " + osReleaseField("ID_LIKE"))
+	model, _ := os.ReadFile("/proc/device-tree/model")
+	switch {
+	case slices.Contains(ids, "raspbian") || strings.Contains(string(model), "Raspberry Pi"):
+		return "pi-gles"
+	case slices.Contains(ids, "debian") || slices.Contains(ids, "ubuntu"):
+		return "debian-cli"
+	}
+	return "fedora-default"
+}
+
+// setupSettings are the questions the first-run setup asks; Enter cycles
+// each one, or opens the prefix picker, like on the settings screen.
+var setupSettings = []setting{
+	cycle("Preset", "none", func(o *options) *string { return &o.preset }, presetNames(nil)...),
+	prefixPicker("Prefix"),
+	cycle("Jobs", "nproc", func(o *options) *string { return &o.jobs }, "", "auto"),
+}
+
+// setupActions follow the questions; the first also installs dependencies.
+var setupActions = []string{"Save and install dependencies", "Save and go to the menu"}
+
+// writeSetupConfig saves the answers as the default config, so later
+// launches go straight to the menu.
+func writeSetupConfig(opts options) error {
+	config := map[string]any{"version": CONFIG_VERSION, "prefix": opts.prefix}
+	if opts.preset != "" {
+		config["preset"] = opts.preset
+	}
+	if opts.jobs != "" {
+		config["jobs"] = opts.jobs
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CONFIG_DIR, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(DEFAULT_CONFIG, append(data, '\n'), 0644)
+}
+
+func renderSetup(opts options, cursor int) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Welcome! A few questions before the first build.") + "\n")
+	s.WriteString(" " + styleLog.Render("Detected "+osRelease()+", so the preset is "+detectedPreset()+" unless you pick another.") + "\n\n")
+	rows := make([]string, 0, len(setupSettings)+len(setupActions))
+	for _, set := range setupSettings {
+		rows = append(rows, set.label+strings.Repeat(" ", max(1, 10-len(set.label)))+set.value(opts))
+	}
+	rows = append(rows, setupActions...)
+	for i, row := range rows {
+		if i == len(setupSettings) {
+			s.WriteString("\n")
+		}
+		if i == cursor {
+			s.WriteString(" " + styleError.Render(">█ ") + styleSelected.Render(row) + "\n")
+		} else {
+			s.WriteString("    " + styleNormal.Render(row) + "\n")
+		}
+	}
+	s.WriteString("\n " + styleLog.Render("Enter changes the answer or saves, Esc skips setup for now"))
+	s.WriteString("\n " + styleLog.Render("The answers go to "+DEFAULT_CONFIG+"; flags on the command line still win."))
+	return s.String()
+}
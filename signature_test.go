@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestVerifySignatureStep(t *testing.T) {
+	tests := []struct {
+		name, ref, sourceDir string
+		want, notWant        []string
+	}{
+		{"tag", "v1.1", "", []string{
+			`git cat-file -t refs/tags/'v1.1'`,
+			`out=$(git verify-tag --raw 'v1.1' 2>&1)`,
+			`else out=$(git verify-commit --raw HEAD 2>&1); fi`,
+		}, nil},
+		{"head", "", "", []string{"out=$(git verify-commit --raw HEAD 2>&1)"}, []string{"verify-tag"}},
+		{"source dir", "v1.1", "/src/TIC-80", []string{"cd '/src/TIC-80' && out=$(git verify-commit --raw HEAD 2>&1)"}, []string{"verify-tag"}},
+	}
+	for _, tt := range tests {
+		opts := defaultOptions()
+		opts.ref, opts.sourceDir = tt.ref, tt.sourceDir
+		cmd := verifySignatureStep("/src/TIC-80", opts).cmd
+		for _, want := range tt.want {
+			if !strings.Contains(cmd, want) {
+				t.Errorf("%s: no %q in\n%s", tt.name, want, cmd)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(cmd, notWant) {
+				t.Errorf("%s: %q in\n%s", tt.name, notWant, cmd)
+			}
+		}
+	}
+}
+
+// TestVerifyTagUsage checks git takes the verify-tag flags the step uses: it
+// exits 129 on a usage error, and 1 here for a tag that isn't signed.
+func TestVerifyTagUsage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.org"}, args...)...)
+		return cmd.Run()
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "x"}, {"tag", "-a", "-m", "x", "v1.1"}} {
+		if err := git(args...); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	var exit *exec.ExitError
+	if err := git("verify-tag", "--raw", "v1.1"); !errors.As(err, &exit) || exit.ExitCode() == 129 {
+		t.Errorf("git verify-tag --raw on an unsigned tag: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// --- LOCAL SOURCE TREE ---
+
+// sourceDir is the TIC-80 tree to build: the --source-dir checkout, or the
+// clone in the build dir.
+func sourceDir(opts options) string {
+	if opts.sourceDir != "" {
+		return opts.sourceDir
+	}
+	return SRC_DIR
+}
+
+// gitIn is the start of a git command line run in dir. A --source-dir tree
+// belongs to whoever checked it out, not to root or the build user, and git
+// refuses such a repository for its "dubious ownership" unless it's listed
+// in safe.directory, here for this one command.
+func gitIn(dir string) string {
+	return fmt.Sprintf("git -c safe.directory=%s -C %s", shellQuote(dir), shellQuote(dir))
+}
+
+// gitInArgs is gitIn for exec.Command("git", ...).
+func gitInArgs(dir string, args ...string) []string {
+	return append([]string{"-c", "safe.directory=" + dir, "-C", dir}, args...)
+}
+
+// checkSourceDir makes sure --source-dir is a TIC-80 tree before anything
+// runs; a missing vendor checkout only warns, some builds do without it.
+func checkSourceDir(dir string) (string, error) {
+	for _, marker := range []string{"CMakeLists.txt", "include/tic80.h"} {
+		if !fileExists(filepath.Join(dir, marker)) {
+			return "", fmt.Errorf("%s is not a TIC-80 source tree (no %s)", dir, marker)
+		}
+	}
+	if !fileExists(filepath.Join(dir, "vendor/sdl2/CMakeLists.txt")) {
+		return fmt.Sprintf("%s has no vendor/sdl2, run `git submodule update --init --recursive` there if the build fails", dir), nil
+	}
+	return "", nil
+}
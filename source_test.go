@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestGitInForeignTree runs the probes as root in a checkout another user
+// owns, which plain git -C refuses as dubious ownership.
+func TestGitInForeignTree(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to hand the tree to another user")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+	uid, _ := strconv.Atoi(nobody.Uid)
+	gid, _ := strconv.Atoi(nobody.Gid)
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	init := exec.Command("bash", "-c", "git init -q && git -c user.name=t -c user.email=t@t commit -q --allow-empty -m t")
+	init.Dir = dir
+	if out, err := init.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	err = filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exec.Command("git", "-C", dir, "rev-parse", "HEAD").Run() == nil {
+		t.Skip("this git doesn't check ownership")
+	}
+	opts := options{sourceDir: dir}
+	if got := checkoutCommit(opts); strings.HasPrefix(got, "unknown") {
+		t.Errorf("checkoutCommit = %q", got)
+	}
+	for _, input := range configureInputs(opts)[:2] {
+		if out, err := exec.Command("bash", "-c", input).CombinedOutput(); err != nil {
+			t.Errorf("%s: %v\n%s", input, err, out)
+		}
+	}
+}
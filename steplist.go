@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// --- STEP LIST ---
+
+// clipboardMsg reports how a command was copied, or why it wasn't. osc52
+// is the text to copy through the terminal instead, when no tool did.
+type clipboardMsg struct {
+	via   string
+	err   error
+	osc52 string
+}
+
+// clipboardTools are tried in order; without any of them the text goes to
+// the terminal as an OSC 52 sequence, which most terminals (and tmux with
+// set-clipboard on) pass to the system clipboard, over SSH too. The sequence
+// goes out as part of View, since only the renderer may write to the
+// terminal while the program runs.
+var clipboardTools = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+func copyToClipboard(text string) tea.Cmd {
+	return func() tea.Msg {
+		for _, tool := range clipboardTools {
+			if _, err := exec.LookPath(tool[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(tool[0], tool[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			if err := cmd.Run(); err == nil {
+				return clipboardMsg{via: tool[0]}
+			}
+		}
+		return clipboardMsg{via: "the terminal (OSC 52)", osc52: text}
+	}
+}
+
+// renderStepList lists the steps of a, with the full command of the one
+// under the cursor below.
+func renderStepList(a action, steps []installStep, cursor, width int, status string) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Steps to "+operationNames[a]) + "\n\n")
+	for i, step := range steps {
+		line := fmt.Sprintf("%2d. %s", i+1, step.desc)
+		if step.nonFatal {
+			line += " (non-fatal)"
+		}
+		if i == cursor {
+			cursor := lipgloss.NewStyle().Foreground(ColorRed).Background(ColorVoid).Render(">█ ")
+			s.WriteString(" " + cursor + styleSelected.Render(line) + "\n")
+		} else {
+			s.WriteString("    " + styleNormal.Render(line) + "\n")
+		}
+	}
+	if len(steps) > 0 {
+		wrap := styleTermText.PaddingLeft(1).Width(max(20, width-2))
+		s.WriteString("\n" + wrap.Render("$ "+steps[cursor].cmd) + "\n")
+	}
+	if status != "" {
+		s.WriteString("\n " + styleLog.Render(status))
+	}
+	s.WriteString("\n " + styleLog.Render("Enter copies the command, Esc to go back"))
+	return s.String()
+}
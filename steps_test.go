@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// HOSTILE_PATH has everything the shell, make and systemd treat specially.
+const HOSTILE_PATH = `/tmp/a b/$x'q"`
+
+// useHostileDirs points the build tree at HOSTILE_PATH for one test.
+func useHostileDirs(t *testing.T) {
+	build, src, epoch, cache, vendor, bundle, ccache := BUILD_DIR, SRC_DIR, EPOCH_FILE, CACHE_DIR, VENDOR_CACHE_DIR, VENDOR_BUNDLE, CCACHE_DIR
+	t.Cleanup(func() {
+		BUILD_DIR, SRC_DIR, EPOCH_FILE, CACHE_DIR, VENDOR_CACHE_DIR, VENDOR_BUNDLE, CCACHE_DIR = build, src, epoch, cache, vendor, bundle, ccache
+	})
+	useCacheDir(HOSTILE_PATH)
+}
+
+func hostileOptions() options {
+	opts := defaultOptions()
+	opts.prefix = HOSTILE_PATH + "/prefix"
+	opts.reproducible = true
+	opts.vendorCache = true
+	opts.installService = true
+	opts.installDemos = true
+	opts.registerMime = true
+	opts.sandbox = "bwrap"
+	opts.output = HOSTILE_PATH + "/out/tic80"
+	opts.preInstallHook = HOSTILE_PATH + "/pre.sh"
+	opts.postInstallHook = HOSTILE_PATH + "/post.sh"
+	opts.mirrors = stringList{"https://example.org/a b/TIC-80.git"}
+	opts.wrapper = "nice -n 19"
+	return opts
+}
+
+// TestStepsParse runs bash -n over every command of every operation, with
+// the build tree, --prefix and --source-dir all on HOSTILE_PATH.
+func TestStepsParse(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	useHostileDirs(t)
+	for _, sourceDir := range []string{"", HOSTILE_PATH + "/src"} {
+		opts := hostileOptions()
+		opts.sourceDir = sourceDir
+		for a, name := range operationNames {
+			for _, step := range getSteps(a, opts) {
+				out, err := exec.Command("bash", "-n", "-c", step.cmd).CombinedOutput()
+				if err != nil {
+					t.Errorf("%s (source dir %q), %s: %v\n%s\n%s", name, sourceDir, step.desc, err, out, step.cmd)
+				}
+			}
+		}
+	}
+}
+
+// TestStepsQuotePaths checks the paths come through bash as one word, not
+// only that the commands parse.
+func TestStepsQuotePaths(t *testing.T) {
+	useHostileDirs(t)
+	opts := hostileOptions()
+	for _, arg := range cmakeArgs(opts) {
+		out, err := exec.Command("bash", "-c", `printf '%s\n' `+arg).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", arg, err)
+		}
+		if words := strings.Count(string(out), "\n"); words != 1 {
+			t.Errorf("%s splits into %d words", arg, words)
+		}
+	}
+	want := "-DCMAKE_INSTALL_PREFIX=" + opts.prefix
+	found := false
+	for _, arg := range cmakeArgs(opts) {
+		out, _ := exec.Command("bash", "-c", `printf %s `+arg).Output()
+		found = found || string(out) == want
+	}
+	if !found {
+		t.Errorf("no cmake argument comes out as %q", want)
+	}
+}
+
+// TestCompilerFlagQuote runs the flag through make and sh the way cmake's
+// build rules do.
+func TestCompilerFlagQuote(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not installed")
+	}
+	flag := "-ffile-prefix-map=" + HOSTILE_PATH + "`y\\z=."
+	dir := t.TempDir()
+	makefile := "C_FLAGS = -DTIC80_PRO " + compilerFlagQuote(flag) + "\nall:\n\t@printf '%s\\n' $(C_FLAGS)\n"
+	if err := os.WriteFile(dir+"/Makefile", []byte(makefile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("make", "-s", "-C", dir).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "-DTIC80_PRO\n"+flag+"\n"; got != want {
+		t.Errorf("make passed %q, want %q", got, want)
+	}
+}
+
+func TestPatchSDLQuotesVersion(t *testing.T) {
+	t.Setenv("SUDO_USER", "")
+	opts := defaultOptions()
+	opts.patchSDL = true
+	opts.sdlVersion = "release-2.30.0; touch pwned"
+	for _, step := range getSteps(actionInstall, opts) {
+		if step.desc != "Patching SDL2..." {
+			continue
+		}
+		if want := "git checkout " + shellQuote(opts.sdlVersion); !strings.HasSuffix(step.cmd, want) {
+			t.Errorf("patch step %q doesn't end in %q", step.cmd, want)
+		}
+		return
+	}
+	t.Fatal("no Patching SDL2 step")
+}
+
+func TestServiceUnitQuotesExecStart(t *testing.T) {
+	opts := defaultOptions()
+	opts.prefix = `/opt/a b/$x%i"q`
+	opts.serviceArgs = "--cli"
+	unit := serviceUnit(opts)
+	want := `ExecStart="/opt/a b/$$x%%i\"q/bin/tic80" --cli`
+	if !strings.Contains(unit, want+"\n") {
+		t.Errorf("unit has no line %q:\n%s", want, unit)
+	}
+}
+
+func TestServiceArgsOneLine(t *testing.T) {
+	for _, args := range []string{"--cli\nExecStartPre=/bin/sh -c id", "--cli\r"} {
+		opts := defaultOptions()
+		opts.serviceArgs = args
+		if err := validateOptions(&opts); err == nil {
+			t.Errorf("--service-args %q accepted", args)
+		}
+	}
+	opts := defaultOptions()
+	opts.serviceArgs = "--cli --fs /srv/carts"
+	if err := validateOptions(&opts); err != nil {
+		t.Errorf("--service-args %q: %v", opts.serviceArgs, err)
+	}
+}
+
+func TestCleanReinstallHooks(t *testing.T) {
+	opts := defaultOptions()
+	opts.preInstallHook = "/usr/local/bin/pre.sh"
+	opts.postInstallHook = "/usr/local/bin/post.sh"
+	steps := getSteps(actionCleanReinstall, opts)
+	if got := steps[0].desc; got != "Running pre-install hook..." {
+		t.Errorf("first step is %q, want the pre-install hook", got)
+	}
+	if got := steps[len(steps)-1].desc; got != "Running post-install hook..." {
+		t.Errorf("last step is %q, want the post-install hook", got)
+	}
+	for _, step := range steps[1:] {
+		if step.desc == "Running pre-install hook..." {
+			t.Error("pre-install hook runs twice")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- PRE-RUN SUMMARY ---
+
+type summaryRow struct {
+	label string
+	value string
+}
+
+// summaryRows lists the resolved configuration shown before a run starts.
+func summaryRows(opts options) []summaryRow {
+	rows := []summaryRow{
+		{"Distro", distroSummary()},
+		{"Preset", presetSummary(opts)},
+		{"Package manager", packageManager(opts)},
+		{"Build dir", buildDirSummary(opts)},
+		{"Ref", refSummary(opts)},
+		{"Jobs", jobsSummary(opts)},
+		{"SDL2", sdlSummary(opts)},
+		{"Renderer", rendererSummary(opts)},
+		{"CMake flags", strings.Join(cmakeArgs(opts), " ")},
+		{"Prefix", prefixSummary(opts)},
+		{"Build user", buildUserSummary(opts)},
+	}
+	if len(opts.mirrors) > 0 && opts.sourceDir == "" {
+		rows = append(rows, summaryRow{"Mirrors", mirrorSummary(opts)})
+	}
+	if opts.label != "" {
+		rows = append(rows, summaryRow{"Label", opts.label})
+	}
+	if opts.output != "" {
+		rows = append(rows, summaryRow{"Output", opts.output})
+	}
+	if opts.submoduleJobs > 0 && opts.sourceDir == "" {
+		rows = append(rows, summaryRow{"Submodules", fmt.Sprintf("fetched %d at a time after the clone", opts.submoduleJobs)})
+	}
+	if opts.verifySignature {
+		rows = append(rows, summaryRow{"Signature", signatureSummary(opts)})
+	}
+	if opts.performance {
+		rows = append(rows, summaryRow{"CPU governor", governorSummary()})
+	}
+	if opts.cache {
+		rows = append(rows, summaryRow{"Cache", "build tree kept, configure skipped if unchanged"})
+	}
+	if opts.vendorCache {
+		rows = append(rows, summaryRow{"Vendor cache", "SDL2 prebuilt in " + VENDOR_CACHE_DIR})
+	}
+	if opts.reproducible {
+		rows = append(rows, summaryRow{"Reproducible", "SOURCE_DATE_EPOCH from commit time, -ffile-prefix-map, Release"})
+	}
+	if opts.sandbox != "" {
+		sandbox := opts.sandbox
+		if !sandboxAvailable(opts) {
+			sandbox += " (not installed, will build unsandboxed)"
+		}
+		rows = append(rows, summaryRow{"Sandbox", sandbox})
+	}
+	if opts.wrapper != "" {
+		rows = append(rows, summaryRow{"Wrapper", opts.wrapper + " (compile only)"})
+	}
+	if opts.registerMime {
+		rows = append(rows, summaryRow{"File type", "*.tic as " + CART_MIME + ", opened by tic80.desktop"})
+	}
+	if opts.installDemos {
+		rows = append(rows, summaryRow{"Demo carts", demosDir()})
+	}
+	if opts.installService {
+		rows = append(rows, summaryRow{"Service", fmt.Sprintf("%s (%s) %s", SERVICE_NAME, opts.serviceScope, opts.serviceArgs)})
+	}
+	return rows
+}
+
+// logHeader opens every run's log with the settings that shape the build.
+func logHeader(a action, opts options) []string {
+	lines := []string{
+		fmt.Sprintf("=== tic80-manager %s, %s", operationNames[a], formatTime(time.Now(), opts.timeFormat, true)),
+		fmt.Sprintf("=== Ref: %s, Prefix: %s, SDL2: %s", refSummary(opts), opts.prefix, sdlSummary(opts)),
+	}
+	if opts.label != "" {
+		lines = append(lines, "=== Label: "+opts.label)
+	}
+	return append(lines, environmentLines(opts)...)
+}
+
+// distroSummary names the running distro from /etc/os-release.
+func distroSummary() string {
+	if name := osReleaseField("PRETTY_NAME"); name != "" {
+		return name
+	}
+	if id := osReleaseField("ID"); id != "" {
+		return strings.TrimSpace(id + " " + osReleaseField("VERSION_ID"))
+	}
+	return "unknown"
+}
+
+func presetSummary(opts options) string {
+	if opts.preset == "" {
+		return "none (Fedora defaults)"
+	}
+	if p, ok := lookupPreset(opts.preset, opts.presets); ok && p.desc != "" {
+		return opts.preset + ": " + p.desc
+	}
+	return opts.preset
+}
+
+// packageManager is the first word of the deps command, dnf unless a preset
+// or --deps-pkgs says otherwise.
+func packageManager(opts options) string {
+	if fields := strings.Fields(opts.depsPkgs); len(fields) > 0 {
+		return fields[0]
+	}
+	return "none"
+}
+
+func buildDirSummary(opts options) string {
+	if opts.sourceDir != "" {
+		return opts.sourceDir + "/build (in the --source-dir tree)"
+	}
+	if opts.cacheDir != "" && CCACHE_DIR != "" {
+		return BUILD_DIR + " (persistent, with ccache)"
+	}
+	if opts.cacheDir != "" {
+		return BUILD_DIR + " (persistent)"
+	}
+	if opts.cache || opts.keepBuild {
+		return BUILD_DIR + " (kept)"
+	}
+	return BUILD_DIR
+}
+
+func refSummary(opts options) string {
+	if opts.sourceDir != "" {
+		return "local tree, as checked out"
+	}
+	if opts.ref == "" {
+		return "default branch"
+	}
+	return opts.ref
+}
+
+func governorSummary() string {
+	current := cpuGovernor()
+	if current == "" {
+		return "cpufreq not available, left alone"
+	}
+	return "performance while compiling (now " + current + ")"
+}
+
+func sdlSummary(opts options) string {
+	if opts.patchSDL {
+		return opts.sdlVersion
+	}
+	return "vendored (patch skipped, --patch-sdl pins " + opts.sdlVersion + ")"
+}
+
+func renderSummary(a action, steps []installStep, opts options, repoKB int64) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Ready to "+operationNames[a]) + "\n\n")
+	rows := summaryRows(opts)
+	if download := downloadSummary(a, opts, repoKB); download != "" {
+		rows = append(rows, summaryRow{"Download", download})
+	}
+	for _, row := range rows {
+		s.WriteString(" " + styleLog.Render(fmt.Sprintf("%-16s", row.label)) + styleNormal.Render(row.value) + "\n")
+	}
+	if len(steps) == 0 {
+		s.WriteString("\n " + styleError.Render(noStepsError(a).Error()+", nothing to run.") + "\n")
+		s.WriteString("\n " + styleLog.Render("Esc to go back"))
+		return s.String()
+	}
+	s.WriteString("\n " + styleLog.Render("Steps:") + "\n")
+	for i, step := range steps {
+		s.WriteString(" " + styleNormal.Render(fmt.Sprintf("%2d. %s", i+1, step.title())) + "\n")
+	}
+	if opts.dryRun {
+		if a == actionUninstall || a == actionCleanReinstall {
+			s.WriteString("\n" + renderRemovalPreview(opts))
+		}
+		s.WriteString("\n " + styleLog.Render("Dry run: press Enter to finish without running, Esc to go back"))
+		return s.String()
+	}
+	s.WriteString("\n " + styleLog.Render("Press Enter to start, Esc to go back"))
+	return s.String()
+}
+
+func prefixSummary(opts options) string {
+	if opts.noInstall {
+		return "not installed (--no-install)"
+	}
+	return opts.prefix
+}
+
+func rendererSummary(opts options) string {
+	if opts.renderer == "sdl" {
+		return "sdl (SDL's renderer, BUILD_SDLGPU=Off)"
+	}
+	return "sdlgpu (BUILD_SDLGPU=On)"
+}
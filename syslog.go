@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// --- REMOTE SYSLOG ---
+
+// Severities from RFC 5424, sent with the user facility.
+const (
+	SYSLOG_ERR     = 3
+	SYSLOG_WARNING = 4
+	SYSLOG_NOTICE  = 5
+	SYSLOG_INFO    = 6
+	SYSLOG_USER    = 1
+)
+
+// SYSLOG_QUEUE is how many messages wait for a slow server before new ones
+// are dropped.
+const SYSLOG_QUEUE = 4096
+
+// SYSLOG_FLUSH_TIMEOUT is how long an exit waits for the queue to go out.
+const SYSLOG_FLUSH_TIMEOUT = 2 * time.Second
+
+// syslogWriter sends every log line to a syslog server as RFC 5424, framed
+// with an octet count over TCP (RFC 6587) and one message per datagram
+// over UDP. Sending is best effort: a lost server never fails a build, and
+// never holds it up either, since one goroutine does all the writing.
+type syslogWriter struct {
+	network  string
+	addr     string
+	conn     net.Conn // only drain uses it
+	hostname string
+	queue    chan string
+	pending  atomic.Int64 // queued or being written
+}
+
+// remoteLog is set from --syslog at startup; every logWriter forwards to
+// it, so it follows the log file in the TUI, headless and control runs.
+var remoteLog *syslogWriter
+
+// parseSyslogAddr takes udp://host:port, tcp://host:port or host:port
+// (UDP), with 514 when the port is left out.
+func parseSyslogAddr(s string) (network, addr string, err error) {
+	network = "udp"
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		if scheme != "udp" && scheme != "tcp" {
+			return "", "", fmt.Errorf("--syslog %q: the scheme must be udp or tcp", s)
+		}
+		network, s = scheme, rest
+	}
+	if s == "" {
+		return "", "", fmt.Errorf("--syslog needs a host")
+	}
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		s = net.JoinHostPort(s, "514")
+	}
+	return network, s, nil
+}
+
+// dialSyslog connects up front so a wrong address fails before the build.
+func dialSyslog(s string) (*syslogWriter, error) {
+	network, addr, err := parseSyslogAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("--syslog: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	w := &syslogWriter{network: network, addr: addr, conn: conn, hostname: hostname, queue: make(chan string, SYSLOG_QUEUE)}
+	go w.drain()
+	return w, nil
+}
+
+// send queues line with a severity from its class; step boundaries (">>> ")
+// are notices with MSGID step, everything else is output. With the queue
+// full the line is dropped rather than waited for.
+func (w *syslogWriter) send(line string, class lineClass) {
+	if w == nil {
+		return
+	}
+	line = ansi.Strip(line)
+	severity, msgID := SYSLOG_INFO, "output"
+	switch {
+	case class == lineError:
+		severity = SYSLOG_ERR
+	case class == lineWarning:
+		severity = SYSLOG_WARNING
+	case strings.HasPrefix(line, ">>> ") || strings.Contains(line, "] >>> "): // or after --timestamps
+		severity, msgID = SYSLOG_NOTICE, "step"
+	}
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s", SYSLOG_USER*8+severity,
+		time.Now().Format(time.RFC3339Nano), w.hostname, APP_NAME, os.Getpid(), msgID, line)
+	if w.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	w.pending.Add(1)
+	select {
+	case w.queue <- msg:
+	default:
+		w.pending.Add(-1)
+	}
+}
+
+// drain writes the queued messages in order.
+func (w *syslogWriter) drain() {
+	for msg := range w.queue {
+		if w.conn == nil || w.write(msg) != nil {
+			// One reconnect, for a TCP server that restarted mid-build.
+			if w.conn != nil {
+				w.conn.Close()
+			}
+			w.conn, _ = net.DialTimeout(w.network, w.addr, 2*time.Second)
+			if w.conn != nil {
+				w.write(msg)
+			}
+		}
+		w.pending.Add(-1)
+	}
+}
+
+// flush gives the queue up to timeout to go out, for a process about to exit.
+func (w *syslogWriter) flush(timeout time.Duration) {
+	if w == nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	for w.pending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (w *syslogWriter) write(msg string) error {
+	w.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	_, err := w.conn.Write([]byte(msg))
+	return err
+}
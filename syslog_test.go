@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSyslogSendDoesNotBlock fills the queue against a TCP server that never
+// reads: send has to drop lines rather than wait for it.
+func TestSyslogSendDoesNotBlock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	w, err := dialSyslog("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough to fill the socket buffers and then the queue behind them.
+	line := strings.Repeat("x", 4096)
+	for i := 0; i < SYSLOG_QUEUE*4; i++ {
+		w.send(line, lineNormal)
+	}
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		w.send(line, lineNormal)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("send took %v with the server not reading", d)
+	}
+}
+
+// TestSyslogSendDelivers checks queued lines still go out, in order, once
+// flushed.
+func TestSyslogSendDelivers(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w, err := dialSyslog(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.send(">>> Building...", lineNormal)
+	w.send("error: broken", lineError)
+	w.flush(SYSLOG_FLUSH_TIMEOUT)
+
+	want := []string{"<13>1 ", "<11>1 "}
+	buf := make([]byte, 2048)
+	for i, prefix := range want {
+		pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if got := string(buf[:n]); !strings.HasPrefix(got, prefix) {
+			t.Errorf("message %d = %q, want prefix %q", i, got, prefix)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// --- DATE AND TIME FORMAT ---
+
+// --time-format is iso, locale or a strftime pattern.
+const DEFAULT_TIME_FORMAT = "iso"
+
+// localeTime is how a region writes dates: the date layout, and whether its
+// clock is 12-hour.
+type localeTime struct {
+	date   string
+	twelve bool
+}
+
+// LOCALE_TIMES is keyed by the territory of LC_TIME (the DE of de_DE.UTF-8).
+// Anything not listed, C and POSIX included, gets ISO 8601.
+var LOCALE_TIMES = map[string]localeTime{
+	"US": {"01/02/2006", true},
+	"PH": {"01/02/2006", true},
+	"CA": {"2006-01-02", true},
+	"AU": {"02/01/2006", true},
+	"NZ": {"02/01/2006", true},
+	"IN": {"02/01/2006", true},
+	"GB": {"02/01/2006", false},
+	"IE": {"02/01/2006", false},
+	"FR": {"02/01/2006", false},
+	"ES": {"02/01/2006", false},
+	"IT": {"02/01/2006", false},
+	"PT": {"02/01/2006", false},
+	"BR": {"02/01/2006", false},
+	"MX": {"02/01/2006", false},
+	"AR": {"02/01/2006", false},
+	"BE": {"02/01/2006", false},
+	"GR": {"02/01/2006", false},
+	"NL": {"02-01-2006", false},
+	"DE": {"02.01.2006", false},
+	"AT": {"02.01.2006", false},
+	"CH": {"02.01.2006", false},
+	"PL": {"02.01.2006", false},
+	"CZ": {"02.01.2006", false},
+	"SK": {"02.01.2006", false},
+	"RU": {"02.01.2006", false},
+	"UA": {"02.01.2006", false},
+	"FI": {"02.01.2006", false},
+	"NO": {"02.01.2006", false},
+	"DK": {"02.01.2006", false},
+	"TR": {"02.01.2006", false},
+	"HU": {"2006.01.02.", false},
+	"JP": {"2006/01/02", false},
+	"CN": {"2006/01/02", false},
+	"TW": {"2006/01/02", false},
+	"KR": {"2006. 01. 02.", false},
+	"SE": {"2006-01-02", false},
+	"LT": {"2006-01-02", false},
+}
+
+// STRFTIME maps the strftime conversions --time-format takes to Go layouts.
+var STRFTIME = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'Z': "MST", 'z': "-0700", 'F': "2006-01-02", 'T': "15:04:05",
+}
+
+// currentLocale is the territory LC_TIME resolves to, as setlocale would pick
+// it from the environment.
+func currentLocale() localeTime {
+	name := os.Getenv("LC_ALL")
+	if name == "" {
+		name = os.Getenv("LC_TIME")
+	}
+	if name == "" {
+		name = os.Getenv("LANG")
+	}
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	if _, territory, ok := strings.Cut(name, "_"); ok {
+		if l, ok := LOCALE_TIMES[territory]; ok {
+			return l
+		}
+	}
+	return localeTime{"2006-01-02", false}
+}
+
+// formatTime writes t as --time-format says: a full timestamp for the log
+// header and the report, or with seconds false the shorter form the history
+// list uses. A strftime pattern is used as it is for both.
+func formatTime(t time.Time, format string, seconds bool) string {
+	switch format {
+	case "iso":
+		if seconds {
+			return t.Format(time.RFC3339)
+		}
+		return t.Format("2006-01-02 15:04")
+	case "locale":
+		l := currentLocale()
+		return t.Format(l.date + " " + clockLayout(l, seconds))
+	}
+	return strftime(t, format)
+}
+
+// formatClock is the time of day --timestamps puts on each log line.
+func formatClock(t time.Time, format string) string {
+	switch format {
+	case "iso":
+		return t.Format("15:04:05")
+	case "locale":
+		return t.Format(clockLayout(currentLocale(), true))
+	}
+	return strftime(t, format)
+}
+
+func clockLayout(l localeTime, seconds bool) string {
+	layout := "15:04"
+	if l.twelve {
+		layout = "3:04"
+	}
+	if seconds {
+		layout += ":05"
+	}
+	if l.twelve {
+		layout += " PM"
+	}
+	return layout
+}
+
+// strftime formats each conversion on its own, so the text around them is
+// never taken for part of a Go layout.
+func strftime(t time.Time, pattern string) string {
+	var s strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			s.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		if layout, ok := STRFTIME[pattern[i]]; ok {
+			s.WriteString(t.Format(layout))
+		} else {
+			s.WriteByte(pattern[i])
+		}
+	}
+	return s.String()
+}
+
+func validTimeFormat(format string) error {
+	if format == "iso" || format == "locale" {
+		return nil
+	}
+	if !strings.Contains(format, "%") {
+		return fmt.Errorf("--time-format must be iso, locale or a strftime pattern like \"%%d.%%m.%%Y %%H:%%M\"")
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 == len(format) {
+			return fmt.Errorf("--time-format ends in a lone %%")
+		}
+		i++
+		if _, ok := STRFTIME[format[i]]; !ok && format[i] != '%' {
+			return fmt.Errorf("--time-format: %%%c is not supported", format[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// --- UNINSTALL ---
+
+type removalTarget struct {
+	desc string
+	path string
+}
+
+// uninstallTargets is every file the uninstall removes, besides the service.
+func uninstallTargets(opts options) []removalTarget {
+	return []removalTarget{
+		{"Removing Binary...", binPath(opts.prefix)},
+		{"Removing Desktop...", filepath.Join(opts.prefix, "share/applications/tic80.desktop")},
+		{"Removing Icon...", filepath.Join(opts.prefix, "share/icons/hicolor/scalable/apps/tic80.svg")},
+	}
+}
+
+func binPath(prefix string) string {
+	return filepath.Join(prefix, "bin", "tic80")
+}
+
+func uninstallSteps(opts options) []installStep {
+	steps := []installStep{serviceRemoveStep(), removeDemosStep(), unregisterMimeStep(opts)}
+	for _, t := range uninstallTargets(opts) {
+		steps = append(steps, installStep{desc: t.desc, cmd: "rm -f " + shellQuote(t.path)})
+	}
+	return steps
+}
+
+// removalPaths includes the unit files of both scopes, matching
+// serviceRemoveStep.
+func removalPaths(opts options) []string {
+	paths := []string{serviceUnitPath("system"), serviceUnitPath("user"), mimePackagePath(opts)}
+	for _, t := range uninstallTargets(opts) {
+		paths = append(paths, t.path)
+	}
+	return append(paths, installedDemos()...)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+var (
+	stylePresent = lipgloss.NewStyle().Foreground(ColorWhite).Background(ColorVoid)
+	styleMissing = lipgloss.NewStyle().Foreground(ColorGrey).Background(ColorVoid)
+)
+
+// renderRemovalPreview lists what an uninstall would delete without touching
+// anything.
+func renderRemovalPreview(opts options) string {
+	var s strings.Builder
+	s.WriteString(" " + styleLog.Render("Would remove:") + "\n")
+	for _, path := range removalPaths(opts) {
+		if fileExists(path) {
+			s.WriteString("   " + stylePresent.Render(path) + "\n")
+		} else {
+			s.WriteString("   " + styleMissing.Render(path+" (already missing)") + "\n")
+		}
+	}
+	return s.String()
+}
+
+func printRemovalPreview(opts options) {
+	fmt.Println("Would remove:")
+	for _, path := range removalPaths(opts) {
+		if fileExists(path) {
+			fmt.Println("  " + path)
+		} else {
+			fmt.Println("  " + path + " (already missing)")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// --- VENDORED DEPENDENCY CACHE ---
+
+// VENDOR_CACHE_DIR keeps prebuilt vendored dependencies, one install tree per
+// set of inputs. It is outside BUILD_DIR, so a clean build doesn't lose it.
+var VENDOR_CACHE_DIR = filepath.Join(CACHE_HOME, "vendor")
+
+// VENDOR_BUNDLE links to the tree the current build uses; cmakeArgs points
+// CMAKE_PREFIX_PATH at it.
+var VENDOR_BUNDLE = VENDOR_CACHE_DIR + "/current"
+
+// vendorBundleStep installs the vendored SDL2, by far the slowest of the
+// vendored libraries to build, into VENDOR_CACHE_DIR, or reuses the tree
+// from an earlier run when the SDL2 checkout, its local changes and the
+// compiler are the same. PREFER_SYSTEM_LIBRARIES then makes TIC-80 pick it
+// up with find_package instead of compiling its own copy.
+func vendorBundleStep(src string, opts options) installStep {
+	sdl := shellQuote(src + "/vendor/sdl2")
+	git := gitIn(src + "/vendor/sdl2")
+	cmd := fmt.Sprintf(`key=$( { %s rev-parse HEAD; %s status --porcelain; cc --version | head -1; } | sha256sum | cut -c1-16 ) && dir=%s/$key && `+
+		`if [ -f "$dir/.complete" ]; then echo "Reusing vendored SDL2 from $dir"; `+
+		`else rm -rf "$dir" "$dir.build" && cmake -S %s -B "$dir.build" -DCMAKE_INSTALL_PREFIX="$dir" -DSDL_SHARED=Off -DSDL_STATIC=On -DSDL_TEST=Off -DCMAKE_POSITION_INDEPENDENT_CODE=On `+
+		`&& %s && cmake --install "$dir.build" && rm -rf "$dir.build" && touch "$dir/.complete"; fi && ln -sfn "$dir" %s`,
+		git, git, shellQuote(VENDOR_CACHE_DIR), sdl, wrapped(`cmake --build "$dir.build" -j`+jobsArg(opts), opts), shellQuote(VENDOR_BUNDLE))
+	step := asBuildUser(sandboxed(installStep{desc: "Building vendored dependencies...", cmd: cmd}, opts), opts)
+	if buildUser(opts) != "" {
+		// Created as well as handed over.
+		return handedOver(step, opts, VENDOR_CACHE_DIR)
+	}
+	// bwrap can only bind a directory that exists.
+	step.cmd = fmt.Sprintf("mkdir -p %s && %s", shellQuote(VENDOR_CACHE_DIR), step.cmd)
+	return step
+}
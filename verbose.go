@@ -0,0 +1,14 @@
+package main
+
+// --- VERBOSE RETRY ---
+
+// VERBOSE_HINT follows a failed compile, on the done screen and headless.
+const VERBOSE_HINT = "make VERBOSE=1 prints the full command line of each compile"
+
+// canRecompileVerbose is whether the run failed compiling, so V on the done
+// screen can run make again, verbosely, in the tree it left. A failed run
+// never gets to Cleaning up..., so the tree is there with or without
+// --keep-build.
+func (m model) canRecompileVerbose() bool {
+	return m.state == stateDone && m.err != nil && m.currentStep < len(m.steps) && m.steps[m.currentStep].desc == "Compiling..."
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestRecompileVerboseKey checks both v and V, as the hint says "Press V",
+// start the verbose recompile after a failed compile, and nothing else does.
+func TestRecompileVerboseKey(t *testing.T) {
+	useTempState(t)
+	useTempLock(t)
+	for _, key := range []string{"v", "V"} {
+		m := initialModel(defaultOptions())
+		m.state, m.err = stateDone, errors.New("compile failed")
+		m.steps = []installStep{{desc: "Configuring..."}, {desc: "Compiling..."}}
+		m.currentStep = 1
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		if got := next.(model); got.pending != actionRecompileVerbose || got.state == stateDone {
+			t.Errorf("%s: pending %s, state %v", key, operationNames[got.pending], got.state)
+		}
+		unlockInstance()
+
+		m.currentStep = 0
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		if got := next.(model); got.state != stateDone {
+			t.Errorf("%s after a failed configure: state %v", key, got.state)
+		}
+	}
+}
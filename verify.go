@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- VERIFY INSTALLATION ---
+
+// sha256sum of every file in the install manifest, saved by the install step.
+var CHECKSUM_FILE = filepath.Join(STATE_DIR, "install-sha256.txt")
+
+// saveChecksums is the shell for the install step, run next to
+// install_manifest.txt after it has been saved. Without a manifest any old
+// checksums go, so they never describe an earlier install.
+func saveChecksums(saveManifest string) string {
+	return fmt.Sprintf("{ %s && xargs -rd '\\n' sha256sum -- < install_manifest.txt > %s || rm -f %s; }",
+		saveManifest, shellQuote(CHECKSUM_FILE), shellQuote(CHECKSUM_FILE))
+}
+
+// fileCheck is one installed file checked against its recorded checksum.
+type fileCheck struct {
+	path   string
+	status string // OK, MODIFIED or MISSING
+}
+
+type verifyMsg struct {
+	checks []fileCheck
+	err    error
+}
+
+func runVerify(opts options) tea.Cmd {
+	return func() tea.Msg {
+		checks, err := verifyInstall(opts)
+		return verifyMsg{checks: checks, err: err}
+	}
+}
+
+// verifyInstall hashes the installed files again, problems first.
+func verifyInstall(opts options) ([]fileCheck, error) {
+	sums, err := readChecksums()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("no checksums recorded yet; the install step saves them, so reinstall (Install Existing Build is enough) to get a baseline")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := sums[binPath(opts.prefix)]; !ok {
+		return nil, fmt.Errorf("the recorded checksums are for an install outside %s; reinstall there, or pass the --prefix it went to", opts.prefix)
+	}
+	var checks []fileCheck
+	for path, want := range sums {
+		got, err := fileSHA256(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			checks = append(checks, fileCheck{path, "MISSING"})
+		case err != nil || got != want:
+			checks = append(checks, fileCheck{path, "MODIFIED"})
+		default:
+			checks = append(checks, fileCheck{path, "OK"})
+		}
+	}
+	slices.SortFunc(checks, func(a, b fileCheck) int {
+		if (a.status == "OK") != (b.status == "OK") {
+			if a.status == "OK" {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.path, b.path)
+	})
+	return checks, nil
+}
+
+// readChecksums reads CHECKSUM_FILE, path to hex digest.
+func readChecksums() (map[string]string, error) {
+	f, err := os.Open(CHECKSUM_FILE)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// sha256sum writes "DIGEST  PATH", or "DIGEST *PATH" in binary mode.
+		digest, path, ok := strings.Cut(scanner.Text(), " ")
+		if ok && len(path) > 1 {
+			sums[path[1:]] = digest
+		}
+	}
+	return sums, scanner.Err()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func renderVerify(checks []fileCheck, err error, done bool, maxRows int) string {
+	var s strings.Builder
+	s.WriteString(" " + styleSelected.Render("Verify Installation") + "\n\n")
+	switch {
+	case !done:
+		s.WriteString(" " + styleLog.Render("Hashing the installed files...") + "\n")
+	case err != nil:
+		s.WriteString(" " + styleError.Render(err.Error()) + "\n")
+	default:
+		bad := 0
+		for i, c := range checks {
+			if c.status != "OK" {
+				bad++
+			}
+			if i == maxRows {
+				s.WriteString("   " + styleLog.Render(fmt.Sprintf("... %d more", len(checks)-i)) + "\n")
+				continue
+			}
+			if i > maxRows {
+				continue
+			}
+			style := styleSuccess
+			if c.status != "OK" {
+				style = styleError
+			}
+			s.WriteString("   " + style.Render(fmt.Sprintf("%-8s", c.status)) + " " + stylePresent.Render(c.path) + "\n")
+		}
+		s.WriteString("\n")
+		if bad == 0 {
+			s.WriteString(" " + styleSuccess.Render(fmt.Sprintf("All %d files match their checksums from the install.", len(checks))) + "\n")
+		} else {
+			s.WriteString(" " + styleError.Render(fmt.Sprintf("%d of %d files were changed or removed since the install.", bad, len(checks))) + "\n")
+			s.WriteString(" " + styleLog.Render("Reinstall to restore them (R on the menu repairs missing files).") + "\n")
+		}
+	}
+	s.WriteString("\n " + styleLog.Render("Checksums from "+CHECKSUM_FILE+", Esc to go back"))
+	return s.String()
+}
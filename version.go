@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// --- INSTALLED VERSION ---
+
+// VERSION_PROBE_TIMEOUT bounds `tic80 --version`, which can hang waiting for
+// a display or audio device.
+const VERSION_PROBE_TIMEOUT = 2 * time.Second
+
+// TIC-80 versions look like 1.1.2837, the last part being the build number.
+var versionRe = regexp.MustCompile(`\b\d+\.\d+\.\d{3,}\b`)
+
+// installedVersionMsg carries the version of the tic80 under the prefix; it
+// is empty when nothing is installed or no version could be found.
+type installedVersionMsg struct {
+	version string
+}
+
+// probeVersion runs off the update loop so a wedged binary can't hold up the
+// menu. If --version doesn't answer in time, the version string compiled
+// into the binary is used instead.
+func probeVersion(opts options) tea.Cmd {
+	return func() tea.Msg {
+		bin := binPath(opts.prefix)
+		if !fileExists(bin) {
+			return installedVersionMsg{}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), VERSION_PROBE_TIMEOUT)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, bin, "--version")
+		// Don't wait on children that kept the output pipe open.
+		cmd.WaitDelay = 500 * time.Millisecond
+		if out, err := cmd.Output(); err == nil {
+			if v := versionRe.Find(out); v != nil {
+				return installedVersionMsg{string(v)}
+			}
+		}
+		data, err := os.ReadFile(bin)
+		if err != nil {
+			return installedVersionMsg{}
+		}
+		return installedVersionMsg{string(versionRe.Find(data))}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- WARNING INDICATOR ---
+
+// warningStatus is the persistent "⚠ 1 warning" line for the running and
+// done views, or "" when no non-fatal step has failed.
+func (m model) warningStatus() string {
+	n := len(m.warned)
+	if n == 0 {
+		return ""
+	}
+	noun := "warning"
+	if n > 1 {
+		noun = "warnings"
+	}
+	last := strings.TrimSuffix(m.steps[m.warned[n-1]].desc, "...")
+	return fmt.Sprintf("⚠ %d %s, last: %s failed · W to jump to it", n, noun, last)
+}
+
+// jumpToWarning opens the log pane at the last warning.
+func (m *model) jumpToWarning() {
+	if len(m.warned) == 0 {
+		return
+	}
+	m.scrollToLine(m.warnLine)
+}
+
+// scrollToLine opens the log pane at line, counting every line pushed. It may
+// have scrolled out of the kept lines, in which case the top is as close as
+// it gets.
+func (m *model) scrollToLine(line int) {
+	m.showTerm = true
+	line -= m.termLines.dropped
+	if m.termLines.dropped > 0 {
+		line++ // the "earlier lines not shown" note
+	}
+	m.viewport.SetYOffset(max(0, line))
+}
+
+// revealFailure opens the log pane when a step fails, at the first error line
+// of the run if there was one, since the lines after it are mostly fallout.
+func (m *model) revealFailure() {
+	if !m.opts.autoLog {
+		return
+	}
+	if m.errLine >= 0 {
+		m.scrollToLine(m.errLine)
+		return
+	}
+	m.showTerm = true
+	m.viewport.GotoBottom()
+}
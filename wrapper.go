@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// --- COMMAND WRAPPER ---
+
+// wrapped puts --wrapper in front of a CPU or IO heavy command, the make and
+// cmake --build that compile, and leaves cmd as it is without one. Clones,
+// downloads and installs are never wrapped: they are short, or wait on the
+// network rather than use the machine.
+func wrapped(cmd string, opts options) string {
+	if opts.wrapper == "" {
+		return cmd
+	}
+	return opts.wrapper + " " + cmd
+}
+
+// validWrapper checks that the first word of --wrapper can be run, so a typo
+// fails here rather than as a compile error after the clone.
+func validWrapper(wrapper string) error {
+	fields := strings.Fields(wrapper)
+	if len(fields) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return fmt.Errorf("--wrapper: %s not found", fields[0])
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// --- XDG BASE DIRECTORIES ---
+
+const APP_NAME = "tic80-manager"
+
+// geteuid is os.Geteuid, a variable so tests can resolve paths as non-root.
+var geteuid = os.Geteuid
+
+// xdgDir resolves one XDG base directory for this tool. An absolute value
+// of env always wins; otherwise root gets the system path and everyone else
+// ~/<home>/tic80-manager, e.g. ~/.config/tic80-manager.
+func xdgDir(env, home, system string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, APP_NAME)
+	}
+	if geteuid() == 0 {
+		return system
+	}
+	if userHome, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(userHome, home, APP_NAME)
+	}
+	return system
+}
+
+var (
+	CONFIG_DIR = xdgDir("XDG_CONFIG_HOME", ".config", "/etc/"+APP_NAME)
+	CACHE_HOME = xdgDir("XDG_CACHE_HOME", ".cache", "/var/cache/"+APP_NAME)
+	STATE_HOME = xdgDir("XDG_STATE_HOME", ".local/state", "/var/lib/"+APP_NAME)
+)
+
+// systemPaths is true when root hasn't asked for XDG dirs of its own, in
+// which case the build tree and log keep their long-standing locations.
+func systemPaths(env string) bool {
+	return os.Getenv(env) == "" && geteuid() == 0
+}
+
+func buildDir() string {
+	if systemPaths("XDG_CACHE_HOME") {
+		// We use /var/tmp to avoid RAM disk limits
+		return "/var/tmp/tic80-build"
+	}
+	return filepath.Join(CACHE_HOME, "build")
+}
+
+func logPath() string {
+	if systemPaths("XDG_STATE_HOME") {
+		return "/var/log/" + APP_NAME + ".log"
+	}
+	return filepath.Join(STATE_HOME, APP_NAME+".log")
+}
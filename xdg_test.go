@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestXdgDir(t *testing.T) {
+	old := geteuid
+	t.Cleanup(func() { geteuid = old })
+
+	tests := []struct {
+		name string
+		uid  int
+		env  string
+		home string
+		want string
+	}{
+		{"absolute env as a user", 1000, "/xdg/config", "/home/u", "/xdg/config/tic80-manager"},
+		{"absolute env as root", 0, "/xdg/config", "/root", "/xdg/config/tic80-manager"},
+		{"relative env is ignored", 1000, "xdg/config", "/home/u", "/home/u/.config/tic80-manager"},
+		{"unset as a user", 1000, "", "/home/u", "/home/u/.config/tic80-manager"},
+		{"unset as root", 0, "", "/root", "/etc/tic80-manager"},
+		{"relative env as root", 0, "xdg/config", "/root", "/etc/tic80-manager"},
+		{"no home as a user", 1000, "", "", "/etc/tic80-manager"},
+	}
+	for _, tt := range tests {
+		uid := tt.uid
+		geteuid = func() int { return uid }
+		t.Setenv("XDG_CONFIG_HOME", tt.env)
+		t.Setenv("HOME", tt.home)
+		if tt.home == "" {
+			os.Unsetenv("HOME")
+		}
+		if got := xdgDir("XDG_CONFIG_HOME", ".config", "/etc/tic80-manager"); got != tt.want {
+			t.Errorf("%s: xdgDir = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSystemPaths(t *testing.T) {
+	old := geteuid
+	t.Cleanup(func() { geteuid = old })
+
+	tests := []struct {
+		uid  int
+		env  string
+		want bool
+	}{
+		{0, "", true},
+		{0, "/xdg/cache", false},
+		{1000, "", false},
+		{1000, "/xdg/cache", false},
+	}
+	for _, tt := range tests {
+		uid := tt.uid
+		geteuid = func() int { return uid }
+		t.Setenv("XDG_CACHE_HOME", tt.env)
+		if got := systemPaths("XDG_CACHE_HOME"); got != tt.want {
+			t.Errorf("uid %d, XDG_CACHE_HOME=%q: systemPaths = %v, want %v", tt.uid, tt.env, got, tt.want)
+		}
+	}
+}